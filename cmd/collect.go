@@ -12,6 +12,8 @@ import (
 var (
 	collectJSONFile string
 	collectSilent   bool
+	collectAll      bool
+	collectEnvrc    bool
 )
 
 // collectCmd represents the collect command.
@@ -19,14 +21,26 @@ var collectCmd = &cobra.Command{
 	Use:   "collect",
 	Short: "Collect repository configurations",
 	Run: func(cmd *cobra.Command, args []string) {
-		result := ""
-		err := config.CollectConfig(collectJSONFile, collectSilent)
-		if err == nil {
-			jsonOutputFile, envOutputFile, _ := config.GetOutputFilePaths(collectJSONFile)
-			result = config.CreateSuccessOutput(jsonOutputFile, envOutputFile)
-
-		} else {
-			result = config.CreateErrorOutput(err)
+		if collectAll {
+			runCollectAll()
+			return
+		}
+		inputFile, found, err := resolveInputFile(collectJSONFile)
+		if err != nil {
+			fmt.Print(config.CreateErrorOutput(err))
+			os.Exit(1)
+		}
+
+		// The status names the output files the collection used, so they aren't
+		// located a second time.
+		result := config.CollectConfigStatus(inputFile, collectSilent)
+		if found && result.Status == config.StatusOK {
+			result.InputFile = inputFile
+		}
+		if collectEnvrc && result.Status == config.StatusOK {
+			if result.EnvrcFile, err = config.WriteEnvrc(inputFile); err != nil {
+				result = config.StatusOutput{Status: config.StatusError, Message: err.Error()}
+			}
 		}
 
 		fmt.Print(result)
@@ -34,12 +48,35 @@ var collectCmd = &cobra.Command{
 	},
 }
 
+// runCollectAll collects every input file of the current repository and prints the
+// aggregated status.
+func runCollectAll() {
+	results, err := config.CollectAllConfigs(".", collectSilent)
+	if err != nil {
+		fmt.Print(config.CreateErrorOutput(err))
+		os.Exit(1)
+	}
+	fmt.Print(config.CreateAggregateOutput(results))
+	for _, result := range results {
+		if result.Status != config.StatusOK {
+			os.Exit(1)
+		}
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(collectCmd)
 
-	// Define the --json flag as required.
-	collectCmd.Flags().StringVarP(&collectJSONFile, "json", "j", "", "Path to the JSON configuration file (required)")
-	collectCmd.MarkFlagRequired("json")
+	// Define the --json flag as optional; the input file is found automatically without it.
+	collectCmd.Flags().StringVarP(&collectJSONFile, "json", "j", "", "Path to the JSON configuration file (default: the closest repo-config.json up to the repository root)")
+
+	// Define the --all flag as optional.
+	collectCmd.Flags().BoolVarP(&collectAll, "all", "a", false, "Collect every input file listed in the repository manifest or named *.repo-config.json")
+	collectCmd.MarkFlagsMutuallyExclusive("json", "all")
+
+	// Define the --envrc flag as optional.
+	collectCmd.Flags().BoolVar(&collectEnvrc, "envrc", false, "Add the lines that load the values with direnv to the .envrc next to the input file")
+	collectCmd.MarkFlagsMutuallyExclusive("envrc", "all")
 
 	// Define the --silent flag as optional.
 	collectCmd.Flags().BoolVarP(&collectSilent, "silent", "s", false, "Run in silent mode")
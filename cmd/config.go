@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/joelong01/repo-config/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// configCmd represents the config command.
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Work with the settings of repo-config itself",
+}
+
+// configShowCmd represents the config show command.
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show the settings in effect and where each one came from",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		output, err := config.FormatSettings(settings, settingSources, settingsFile)
+		if err != nil {
+			fmt.Print(config.CreateErrorOutput(err))
+			os.Exit(1)
+		}
+		fmt.Print(output)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configShowCmd)
+}
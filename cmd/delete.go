@@ -18,11 +18,16 @@ var deleteCmd = &cobra.Command{
     Use:   "delete",
     Short: "Delete repository configuration output files",
     Run: func(cmd *cobra.Command, args []string) {
+        inputFile, found, err := resolveInputFile(deleteJSONFile)
+        if err != nil {
+            fmt.Print(config.CreateErrorOutput(err))
+            os.Exit(1)
+        }
+
         result := ""
-        err := config.DeleteConfig(deleteJSONFile, deleteSilent, os.Stdin)
+        err = config.DeleteConfig(inputFile, deleteSilent, os.Stdin)
         if err == nil {
-			jsonOutputFile, envOutputFile, _ := config.GetOutputFilePaths(collectJSONFile)
-			result = config.CreateSuccessOutput(jsonOutputFile, envOutputFile)
+			result = createSuccessOutput(inputFile, found)
 
 		} else {
 			result = config.CreateErrorOutput(err)
@@ -37,9 +42,8 @@ var deleteCmd = &cobra.Command{
 func init() {
     rootCmd.AddCommand(deleteCmd)
 
-    // Define the --json flag as required
-    deleteCmd.Flags().StringVarP(&deleteJSONFile, "json", "j", "", "Path to the JSON configuration file (required)")
-    deleteCmd.MarkFlagRequired("json")
+    // Define the --json flag as optional; the input file is found automatically without it
+    deleteCmd.Flags().StringVarP(&deleteJSONFile, "json", "j", "", "Path to the JSON configuration file (default: the closest repo-config.json up to the repository root)")
 
     // Define the --silent flag as optional
     deleteCmd.Flags().BoolVarP(&deleteSilent, "silent", "s", false, "Run in silent mode")
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/joelong01/repo-config/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// Variables for flags.
+var (
+	direnvJSONFile string
+	direnvEnvrc    bool
+)
+
+// direnvCmd represents the direnv command.
+var direnvCmd = &cobra.Command{
+	Use:   "direnv",
+	Short: "Print the direnv function that loads the collected values",
+	Long: `Prints the direnv stdlib function use_repo_config, which exports the values
+collected for an input file and reloads when the input file or its values change.
+Add it to ~/.config/direnv/direnvrc:
+
+  eval "$(repo-config direnv)"
+
+and load the values in an .envrc with:
+
+  use repo_config [input file]
+
+With --envrc it prints the lines to add to an .envrc in the current directory
+instead; they define use_repo_config themselves if direnvrc doesn't.  collect
+--envrc adds them to the .envrc next to the input file.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		// The function owns stdout, so errors go to stderr.
+		if direnvEnvrc {
+			inputFile, _, err := resolveInputFile(direnvJSONFile)
+			if err != nil {
+				fmt.Fprint(os.Stderr, config.CreateErrorOutput(err))
+				os.Exit(1)
+			}
+			snippet, err := config.EnvrcSnippet(".", inputFile)
+			if err != nil {
+				fmt.Fprint(os.Stderr, config.CreateErrorOutput(err))
+				os.Exit(1)
+			}
+			fmt.Print(snippet)
+			return
+		}
+		executable, err := os.Executable()
+		if err != nil {
+			executable = "repo-config"
+		}
+		fmt.Print(config.DirenvFunction(executable))
+	},
+}
+
+// direnvExportCmd is what use_repo_config runs whenever direnv loads an .envrc.
+var direnvExportCmd = &cobra.Command{
+	Use:    "direnv-export",
+	Short:  "Print the statements use_repo_config evaluates",
+	Hidden: true,
+	Args:   cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		inputFile, _, err := resolveInputFile(direnvJSONFile)
+		if err == nil {
+			var statements string
+			statements, err = config.DirenvExport(inputFile)
+			fmt.Print(statements)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "repo-config: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(direnvCmd)
+	rootCmd.AddCommand(direnvExportCmd)
+
+	// Define the --json flag as optional; the input file is found automatically without it.
+	for _, cmd := range []*cobra.Command{direnvCmd, direnvExportCmd} {
+		cmd.Flags().StringVarP(&direnvJSONFile, "json", "j", "", "Path to the JSON configuration file (default: the closest repo-config.json up to the repository root)")
+	}
+
+	// Define the --envrc flag as optional.
+	direnvCmd.Flags().BoolVar(&direnvEnvrc, "envrc", false, "Print the lines to add to an .envrc instead")
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/joelong01/repo-config/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// Variables for flags.
+var (
+	envJSONFile string
+	envShell    string
+	envUnset    bool
+)
+
+// envCmd represents the env command.
+var envCmd = &cobra.Command{
+	Use:   "env",
+	Short: "Print statements that export the collected values in a shell",
+	Long: `Collects the values of the input file like collect --silent and prints the
+statements that export their environment variables, for the shell to evaluate:
+
+bash, zsh:   eval "$(repo-config env --shell bash)"
+fish:        repo-config env --shell fish | source
+powershell:  repo-config env --shell powershell | Out-String | Invoke-Expression
+nushell:     repo-config env --shell nushell | from json | load-env
+
+With --unset it prints the statements that remove them again instead.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		// The statements own stdout, so errors go to stderr.
+		output, err := formatEnv()
+		if err != nil {
+			fmt.Fprint(os.Stderr, config.CreateErrorOutput(err))
+			os.Exit(1)
+		}
+		fmt.Print(output)
+	},
+}
+
+// formatEnv returns the statements the env command prints.
+func formatEnv() (string, error) {
+	inputFile, _, err := resolveInputFile(envJSONFile)
+	if err != nil {
+		return "", err
+	}
+	if envShell == "" {
+		envShell = config.DefaultShell()
+	}
+	if envUnset {
+		names, err := config.EnvVarNames(inputFile)
+		if err != nil {
+			return "", err
+		}
+		return config.FormatUnsets(envShell, names)
+	}
+	vars, err := config.CollectEnv(inputFile)
+	if err != nil {
+		return "", err
+	}
+	return config.FormatExports(envShell, vars)
+}
+
+func init() {
+	rootCmd.AddCommand(envCmd)
+
+	// Define the --json flag as optional; the input file is found automatically without it.
+	envCmd.Flags().StringVarP(&envJSONFile, "json", "j", "", "Path to the JSON configuration file (default: the closest repo-config.json up to the repository root)")
+
+	// Define the --shell flag as optional.
+	envCmd.Flags().StringVar(&envShell, "shell", "", "Shell to print statements for: "+strings.Join(config.Shells, ", ")+" (default: $SHELL, or bash)")
+
+	// Define the --unset flag as optional.
+	envCmd.Flags().BoolVar(&envUnset, "unset", false, "Print statements that remove the variables instead")
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/joelong01/repo-config/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var execJSONFile string
+
+// execCmd represents the exec command.
+var execCmd = &cobra.Command{
+	Use:   "exec [--json <file>] -- <command> [args...]",
+	Short: "Run a command with the collected values in its environment",
+	Long: `Collects the values of the input file like collect --silent and runs the command
+with their environment variables added to its environment only, so the shell that
+runs it never sees them.  Signals are forwarded to the command, and repo-config exits
+with its exit code.  For example:
+
+repo-config exec --json settings.json -- docker compose up`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		// The command owns stdout, so failures before it starts go to stderr.
+		inputFile, _, err := resolveInputFile(execJSONFile)
+		if err != nil {
+			fmt.Fprint(os.Stderr, config.CreateErrorOutput(err))
+			os.Exit(1)
+		}
+		vars, err := config.CollectEnv(inputFile)
+		if err != nil {
+			fmt.Fprint(os.Stderr, config.CreateErrorOutput(err))
+			os.Exit(1)
+		}
+		code, err := config.RunCommand(args, vars)
+		if err != nil {
+			fmt.Fprint(os.Stderr, config.CreateErrorOutput(err))
+			os.Exit(127)
+		}
+		os.Exit(code)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(execCmd)
+
+	// Flags after the command belong to it.
+	execCmd.Flags().SetInterspersed(false)
+
+	// Define the --json flag as optional; the input file is found automatically without it.
+	execCmd.Flags().StringVarP(&execJSONFile, "json", "j", "", "Path to the JSON configuration file (default: the closest repo-config.json up to the repository root)")
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/joelong01/repo-config/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// hookCmd represents the hook command.
+var hookCmd = &cobra.Command{
+	Use:   "hook <" + strings.Join(config.HookShells, "|") + ">",
+	Short: "Print a prompt hook that loads the values of the current project",
+	Long: `Prints a hook for the shell's startup file that loads the values of the
+current project's input file whenever the directory changes, and removes the
+previous project's variables.  It never prompts; run collect to fill in values.
+
+bash: eval "$(repo-config hook bash)"   in ~/.bashrc
+zsh:  eval "$(repo-config hook zsh)"    in ~/.zshrc
+fish: repo-config hook fish | source    in ~/.config/fish/config.fish`,
+	Args:      cobra.ExactArgs(1),
+	ValidArgs: config.HookShells,
+	Run: func(cmd *cobra.Command, args []string) {
+		// The hook owns stdout, so errors go to stderr.
+		executable, err := os.Executable()
+		if err != nil {
+			executable = "repo-config"
+		}
+		hook, err := config.FormatHook(args[0], executable)
+		if err != nil {
+			fmt.Fprint(os.Stderr, config.CreateErrorOutput(err))
+			os.Exit(1)
+		}
+		fmt.Print(hook)
+	},
+}
+
+// hookEnvCmd is what the prompt hook runs at every prompt.
+var hookEnvCmd = &cobra.Command{
+	Use:    "hook-env <" + strings.Join(config.HookShells, "|") + ">",
+	Short:  "Print the statements the prompt hook evaluates",
+	Hidden: true,
+	Args:   cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dir, err := os.Getwd()
+		if err == nil {
+			var statements string
+			statements, err = config.HookEnv(args[0], dir, os.Getenv(config.HookStateEnvVar))
+			fmt.Print(statements)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "repo-config: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(hookCmd)
+	rootCmd.AddCommand(hookEnvCmd)
+}
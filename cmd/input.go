@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"github.com/joelong01/repo-config/internal/config"
+)
+
+// resolveInputFile returns the input file to use: the one passed with --json, or else
+// the closest conventionally named one between the current directory and the root of
+// the repository.  found reports whether the file was found automatically.
+func resolveInputFile(jsonFile string) (inputFile string, found bool, err error) {
+	if jsonFile != "" {
+		return jsonFile, false, nil
+	}
+	inputFile, err = config.FindInputFile(".")
+	if err != nil {
+		return "", false, err
+	}
+	return inputFile, true, nil
+}
+
+// createSuccessOutput creates the success output for inputFile, naming the input file
+// when it was found automatically.
+func createSuccessOutput(inputFile string, found bool) string {
+	jsonOutputFile, envOutputFile, _ := config.GetOutputFilePaths(inputFile)
+	if found {
+		return config.CreateSuccessOutputForInput(inputFile, jsonOutputFile, envOutputFile)
+	}
+	return config.CreateSuccessOutput(jsonOutputFile, envOutputFile)
+}
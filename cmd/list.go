@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/joelong01/repo-config/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// listCmd represents the list command.
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the input files of the repository and their output files",
+	Run: func(cmd *cobra.Command, args []string) {
+		results, err := config.ListConfigs(".")
+		if err != nil {
+			fmt.Print(config.CreateErrorOutput(err))
+			os.Exit(1)
+		}
+		fmt.Print(config.CreateAggregateOutput(results))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(listCmd)
+}
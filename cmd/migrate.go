@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/joelong01/repo-config/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// migrateCmd represents the migrate command.
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Move the values stored in ~/.repo-config to the configured store directory",
+	Long: `Moves the values stored in ~/.repo-config to the directory set with --store-dir,
+$` + config.StoreDirEnvVar + `, $XDG_STATE_HOME or $XDG_CONFIG_HOME, and replaces
+~/.repo-config with a symlink to it.  For example, to keep values on a volume that
+survives rebuilding a devcontainer:
+
+repo-config migrate --store-dir /mnt/repo-config`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		storeDir, err := config.MigrateStore()
+		if err != nil {
+			fmt.Print(config.CreateErrorOutput(err))
+			os.Exit(1)
+		}
+		fmt.Print(config.StatusOutput{Status: config.StatusOK, Message: fmt.Sprintf("values moved to %s", storeDir)})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(migrateCmd)
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/joelong01/repo-config/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// pluginCmd represents the plugin command.
+var pluginCmd = &cobra.Command{
+	Use:   "plugin",
+	Short: "Query provider plugins",
+	Long: `Queries the provider plugin repo-config-provider-<name> on PATH, which resolves
+settings whose source is plugin:<name>:<ref>.`,
+}
+
+// pluginCapabilitiesCmd represents the plugin capabilities command.
+var pluginCapabilitiesCmd = &cobra.Command{
+	Use:   "capabilities <name>",
+	Short: "Show the operations a provider plugin supports",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		operations, err := config.PluginCapabilities(args[0])
+		printPluginOutput(struct {
+			Name       string   `json:"name"`
+			Operations []string `json:"operations"`
+		}{args[0], operations}, err)
+	},
+}
+
+// pluginListCmd represents the plugin list command.
+var pluginListCmd = &cobra.Command{
+	Use:   "list <name> [prefix]",
+	Short: "List the refs a provider plugin can resolve",
+	Args:  cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		prefix := ""
+		if len(args) > 1 {
+			prefix = args[1]
+		}
+		refs, err := config.ListPluginRefs(args[0], prefix)
+		printPluginOutput(struct {
+			Name string   `json:"name"`
+			Refs []string `json:"refs"`
+		}{args[0], refs}, err)
+	},
+}
+
+// printPluginOutput prints output as indented JSON, or the error output if err is set.
+func printPluginOutput(output interface{}, err error) {
+	if err == nil {
+		var content []byte
+		if content, err = json.MarshalIndent(output, "", "  "); err == nil {
+			fmt.Println(string(content))
+			return
+		}
+	}
+	fmt.Print(config.CreateErrorOutput(err))
+	os.Exit(1)
+}
+
+func init() {
+	rootCmd.AddCommand(pluginCmd)
+	pluginCmd.AddCommand(pluginCapabilitiesCmd)
+	pluginCmd.AddCommand(pluginListCmd)
+}
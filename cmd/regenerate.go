@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/joelong01/repo-config/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var regenerateJSONFile string
+
+// regenerateCmd represents the regenerate command.
+var regenerateCmd = &cobra.Command{
+	Use:   "regenerate <key> [key...]",
+	Short: "Generate new random values for settings that have a generate spec",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		inputFile, found, err := resolveInputFile(regenerateJSONFile)
+		if err != nil {
+			fmt.Print(config.CreateErrorOutput(err))
+			os.Exit(1)
+		}
+
+		result := ""
+		err = config.RegenerateConfig(inputFile, args)
+		if err == nil {
+			result = createSuccessOutput(inputFile, found)
+		} else {
+			result = config.CreateErrorOutput(err)
+		}
+
+		fmt.Print(result)
+		if err != nil {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(regenerateCmd)
+
+	// Define the --json flag as optional; the input file is found automatically without it.
+	regenerateCmd.Flags().StringVarP(&regenerateJSONFile, "json", "j", "", "Path to the JSON configuration file (default: the closest repo-config.json up to the repository root)")
+}
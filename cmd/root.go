@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/joelong01/repo-config/internal/config"
 	"github.com/spf13/cobra"
 )
 
@@ -23,6 +24,39 @@ repo-config collect --json setting.json --silent`,
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	// Run: func(cmd *cobra.Command, args []string) { },
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if err := loadSettings(cmd); err != nil {
+			fmt.Print(config.CreateErrorOutput(err))
+			os.Exit(1)
+		}
+	},
+}
+
+// Variables for the settings.
+var (
+	// settingsFile is the settings file passed with --config.
+	settingsFile string
+	// settings are the settings in effect, and settingSources where each came from.
+	settings       config.Settings
+	settingSources map[string]string
+)
+
+// loadSettings loads the settings file, the environment and the setting flags that
+// were given to cmd, and makes the result the settings used by the config package.
+func loadSettings(cmd *cobra.Command) error {
+	flags := map[string]string{}
+	for _, flag := range config.SettingFlags() {
+		if cmd.Flags().Changed(flag.Name) {
+			flags[flag.Name], _ = cmd.Flags().GetString(flag.Name)
+		}
+	}
+	var err error
+	settings, settingSources, err = config.LoadSettings(settingsFile, flags)
+	if err != nil {
+		return err
+	}
+	config.UseSettings(settings)
+	return nil
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -39,11 +73,13 @@ func init() {
 	// Cobra supports persistent flags, which, if defined here,
 	// will be global for your application.
 
-	// rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.collectConfig.yaml)")
-
-	// Cobra also supports local flags, which will only run
-	// when this action is called directly.
+	rootCmd.PersistentFlags().StringVar(&settingsFile, "config", "", "Settings file (default is $"+config.SettingsFileEnvVar+" or ~/.config/repo-config/config.yaml)")
 
+	// Every setting can be overridden by a flag, which wins over its environment
+	// variable and the settings file.
+	for _, flag := range config.SettingFlags() {
+		rootCmd.PersistentFlags().String(flag.Name, "", flag.Usage)
+	}
 }
 
 
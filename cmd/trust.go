@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/joelong01/repo-config/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// Variables for flags.
+var trustJSONFile string
+
+// trustCmd represents the trust command.
+var trustCmd = &cobra.Command{
+	Use:   "trust",
+	Short: "Approve the scripts of the input file",
+	Long: `Shows the shellscripts and cmd sources of the input file that haven't been
+approved for this repo yet, and approves them, so collect --silent runs them.
+Review the input file before approving its scripts: they run as you.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		inputFile, _, err := resolveInputFile(trustJSONFile)
+		if err != nil {
+			fmt.Print(config.CreateErrorOutput(err))
+			os.Exit(1)
+		}
+		approved, err := config.TrustScripts(inputFile)
+		if err != nil {
+			fmt.Print(config.CreateErrorOutput(err))
+			os.Exit(1)
+		}
+		message := "no scripts to approve"
+		if len(approved) > 0 {
+			message = "approved the scripts of " + strings.Join(approved, ", ")
+		}
+		fmt.Print(config.StatusOutput{Status: config.StatusOK, Message: message, InputFile: inputFile})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(trustCmd)
+
+	// Define the --json flag as optional; the input file is found automatically without it.
+	trustCmd.Flags().StringVarP(&trustJSONFile, "json", "j", "", "Path to the JSON configuration file (default: the closest repo-config.json up to the repository root)")
+}
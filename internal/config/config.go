@@ -2,17 +2,15 @@ package config
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"sort"
 	"strconv"
 	"strings"
 	"text/tabwriter"
-	"time"
 )
 
 // ItemConfig represents the structure of each configuration item.
@@ -22,184 +20,172 @@ type ItemConfig struct {
 	Default                     string `json:"default"`
 	TempEnvironmentVariableName string `json:"tempEnvironmentVariableName"`
 	RequiredAsEnv               bool   `json:"requiredAsEnv"`
+	// Generate fills in a missing value with a random one the first time it is collected.
+	Generate *GenerateSpec `json:"generate,omitempty"`
+	// When limits the item to setups where the expression over other settings is true.
+	When string `json:"when,omitempty"`
+	// Required defaults to true.  Optional items may be left empty.
+	Required *bool `json:"required,omitempty"`
+	// EmptyIsValid records that the user chose an empty value on purpose.
+	EmptyIsValid bool `json:"-"`
+	// Source says where the value comes from, e.g. "env:NAME" (see Provider).  The
+	// default is the store: typed in and kept in the values file.
+	Source string `json:"source,omitempty"`
+	// Secret marks a value that is a secret.  Its source may be just a reference,
+	// which is looked up in the secretBackend setting (see applySecretBackend).
+	Secret bool `json:"secret,omitempty"`
+	// Timeout, Cwd, Shell and Env control how the item's shellscript or cmd source
+	// runs (see scriptRunner).
+	Timeout string            `json:"timeout,omitempty"`
+	Cwd     string            `json:"cwd,omitempty"`
+	Shell   string            `json:"shell,omitempty"`
+	Env     map[string]string `json:"env,omitempty"`
+	// Group is an optional heading the item is shown under in the interactive table.
+	Group string `json:"group,omitempty"`
+	// Order is the position of the item in the input file.
+	Order int `json:"-"`
 	// Add any additional fields if necessary.
 }
 
 // CollectConfig loads and processes the configuration based on the input JSON file and silent mode.
 func CollectConfig(inputJSONFile string, silent bool) error {
-	// Check if the input JSON file exists.
-	if _, err := os.Stat(inputJSONFile); os.IsNotExist(err) {
-		return fmt.Errorf("JSON file '%s' not found", inputJSONFile)
-	}
-
-	// Load the input JSON file.
-	configMap, err := loadConfigFile(inputJSONFile)
+	s, err := newSession(inputJSONFile)
 	if err != nil {
 		return err
 	}
+	defer s.close()
+	return s.collect(silent, os.Stdin)
+}
 
-	// Determine the output file paths.
-	jsonOutputFile, _, err := GetOutputFilePaths(inputJSONFile)
+// CollectConfigStatus runs CollectConfig and returns the status to report, naming the
+// output files the collection used.
+func CollectConfigStatus(inputJSONFile string, silent bool) StatusOutput {
+	s, err := newSession(inputJSONFile)
 	if err != nil {
-		return err
+		return newErrorStatus(err)
 	}
-
-	// Load existing values from the output JSON file if it exists.
-	existingValues, err := loadExistingValues(inputJSONFile, jsonOutputFile)
-	if err != nil {
-		return err
+	defer s.close()
+	if err := s.collect(silent, os.Stdin); err != nil {
+		status := newErrorStatus(err)
+		status.Errors = append(append([]SourceError{}, s.scriptErrors...), status.Errors...)
+		return status
 	}
-
-	// Update configMap with existing values
-	updateConfigMapWithExistingValues(configMap, existingValues)
-
-	// Handle silent mode.
-	if silent {
-		// Get modification times.
-		inputJSONModTime, err := getFileModTime(inputJSONFile)
-		if err != nil {
-			return err
-		}
-
-		outputJSONModTime, err := getFileModTime(jsonOutputFile)
-		if err != nil && !os.IsNotExist(err) {
-			return err
-		}
-		outputFileExists := false
-		// Check if input JSON is newer than output JSON or output doesn't exist.
-		if !outputFileExists || inputJSONModTime.After(outputJSONModTime) {
-			// Check for new or deleted settings.
-			hasChanges := compareConfigs(configMap, existingValues)
-			if hasChanges {
-				// New settings found or settings deleted, proceed interactively.
-				fmt.Fprintln(os.Stderr, "Configuration changes detected. Proceeding interactively.")
-				return interactiveConfig(configMap, inputJSONFile, os.Stdin)
-			} else {
-				// No changes, save updated config silently.
-				return saveConfig(inputJSONFile, configMap)
-			}
-		} else {
-			// Input JSON is older or same age.
-			// Verify all settings have values.
-			missingValues := checkForMissingValues(configMap)
-			if len(missingValues) > 0 {
-				// Missing values found, proceed interactively.
-				fmt.Fprintln(os.Stderr, "Missing values detected. Proceeding interactively.")
-				return interactiveConfig(configMap, inputJSONFile, os.Stdin)
-			} else {
-				// All values present, save and return.  we need to save in case
-				// a setting has been deleted.
-				return saveConfig(inputJSONFile, configMap)
-			}
-		}
-	}
-
-	// Not in silent mode or silent mode overridden, proceed interactively.
-	return interactiveConfig(configMap, inputJSONFile, os.Stdin)
+	status := newSuccessStatus(s.jsonOutputFile, s.envOutputFile)
+	status.Errors = s.scriptErrors
+	return status
 }
 
 // updateConfigMapWithExistingValues updates configMap with values from existingValues.
+// Settings listed under emptyValuesKey, which loadExistingValues keeps as the JSON
+// it read, are marked as intentionally empty.
 func updateConfigMapWithExistingValues(configMap map[string]ItemConfig, existingValues map[string]string) {
+	emptyValues := map[string]bool{}
+	if raw, exists := existingValues[emptyValuesKey]; exists {
+		emptyValues, _ = parseEmptyValues(json.RawMessage(raw))
+	}
 	for key, item := range configMap {
 		if value, exists := existingValues[key]; exists {
 			item.Default = value
+			item.EmptyIsValid = value == "" && emptyValues[key]
 			configMap[key] = item
 		}
 	}
 }
 
-// loadConfigFile loads and parses the JSON configuration file into a map.
+// loadConfigFile loads and parses the configuration file into a map.  The file may be
+// JSON, JSON with comments and trailing commas, or YAML (see detectFormat).
+// The position of each item in the file is kept in ItemConfig.Order.
+// Included files and $ref definitions are merged in (see configLoader).
 func loadConfigFile(jsonFile string) (map[string]ItemConfig, error) {
-	file, err := os.Open(jsonFile)
+	loader, err := newConfigLoader(jsonFile)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open JSON file: %v", err)
+		return nil, err
 	}
-	defer file.Close()
-
-	decoder := json.NewDecoder(file)
-	configMap := make(map[string]ItemConfig)
-	if err := decoder.Decode(&configMap); err != nil {
-		return nil, fmt.Errorf("failed to parse JSON file: %v", err)
+	configMap, err := loader.load()
+	if err != nil {
+		return nil, err
+	}
+	if err := validateWhen(configMap); err != nil {
+		return nil, err
+	}
+	if err := applySecretBackend(configMap); err != nil {
+		return nil, err
+	}
+	if err := validateScriptOptions(configMap); err != nil {
+		return nil, err
 	}
 	return configMap, nil
 }
 
 // GetOutputFilePaths determines the output file paths based on the input JSON file.
-// Now derives the project name from the Git repository.
+// Now derives the project name from the Git repository, and the file names from the
+// path of the input file within it.
 func GetOutputFilePaths(inputJSONFile string) (string, string, error) {
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return "", "", fmt.Errorf("failed to get home directory: %v", err)
-	}
-
-	// Get the absolute path of the input JSON file
-	absInputJSONFile, err := filepath.Abs(inputJSONFile)
-	if err != nil {
-		return "", "", fmt.Errorf("failed to get absolute path of input JSON file: %v", err)
-	}
-
-	// Determine the project name from the Git repository
-	projectName, err := getProjectName(absInputJSONFile)
+	files, err := locateOutputFiles(inputJSONFile)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to get Git project name: %v", err)
+		return "", "", err
 	}
 
 	// Build the output directory path including the project name
-	outputDir := filepath.Join(homeDir, ".repo-config", projectName)
-	if _, err := os.Stat(outputDir); os.IsNotExist(err) {
-		if err := os.MkdirAll(outputDir, 0755); err != nil {
-			return "", "", fmt.Errorf("failed to create directory '%s': %v", outputDir, err)
-		}
+	if _, err := ensureProjectDir(files.storeDir, files.project.name, files.project.alias); err != nil {
+		return "", "", err
 	}
-
-	baseFilename := filepath.Base(inputJSONFile)
-	ext := filepath.Ext(baseFilename)
-	nameWithoutExt := strings.TrimSuffix(baseFilename, ext)
-	jsonOutputFile := filepath.Join(outputDir, fmt.Sprintf(".%s-values.json", nameWithoutExt))
-	envOutputFile := filepath.Join(outputDir, fmt.Sprintf(".%s-values.env", nameWithoutExt))
-
-	return jsonOutputFile, envOutputFile, nil
+	return files.json, files.env, nil
 }
 
-// getProjectName determines the project name based on the Git repository.
-// if it is not a git respository, it returns the current directofy as
-// the project name.
-// TODO: have the container set an environment variable that can be used
-//
-//	as the project name and also add a --project-name as an optional
-//	flag that will override this.
-//
-// It returns the base name of the Git repository's root directory.
-func getProjectName(startPath string) (string, error) {
-	// Prepare the command to get the top-level directory of the Git repository
-	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
-	cmd.Dir = filepath.Dir(startPath)
-	gitRootPath := ""
-	// Execute the command and capture the output
-	output, err := cmd.Output()
+// outputFiles are where the values of an input file are kept.
+type outputFiles struct {
+	project  projectInfo
+	storeDir string
+	// name is what the output files are named after; legacyName is what older
+	// versions named them after.
+	name       string
+	legacyName string
+	json       string
+	env        string
+}
 
+// locateOutputFiles determines the output files of inputFile without touching them.
+func locateOutputFiles(inputFile string) (outputFiles, error) {
+	storeDir, err := StoreDir()
 	if err != nil {
-		gitRootPath = os.Getenv("PWD")
-
-	} else {
-		// Get the path to the top-level directory
-		gitRootPath = strings.TrimSpace(string(output))
+		return outputFiles{}, err
 	}
 
-	// Extract the base name as the project name
+	// Get the absolute path of the input JSON file
+	absInputFile, err := filepath.Abs(inputFile)
+	if err != nil {
+		return outputFiles{}, fmt.Errorf("failed to get absolute path of input JSON file: %v", err)
+	}
 
-	projectName := filepath.Base(gitRootPath)
-	return projectName, nil
+	// Determine the project from the Git repository
+	project := resolveProject(absInputFile)
+	outputDir := filepath.Join(storeDir, project.name)
+
+	// Name the output files after the input's path in the repository so that input
+	// files with the same name in different directories don't share values.
+	name := outputName(project, absInputFile)
+	return outputFiles{
+		project:    project,
+		storeDir:   storeDir,
+		name:       name,
+		legacyName: outputBaseName(absInputFile),
+		json:       filepath.Join(outputDir, fmt.Sprintf(".%s-values.json", name)),
+		env:        filepath.Join(outputDir, fmt.Sprintf(".%s-values.env", name)),
+	}, nil
 }
 
-// loadExistingValues loads existing values from the output JSON file.
-func loadExistingValues(inputJSONFile, jsonOutputFile string) (map[string]string, error) {
-	// Load the input JSON file
-	inputConfig, err := loadConfigFile(inputJSONFile)
-	if err != nil {
-		return nil, fmt.Errorf("failed to load input JSON file: %v", err)
-	}
+// outputBaseName returns the input file name without its extension.  The output
+// files are always JSON and ENV, so settings.json, settings.jsonc and settings.yaml
+// all share the same values.
+func outputBaseName(inputFile string) string {
+	baseFilename := filepath.Base(inputFile)
+	return strings.TrimSuffix(baseFilename, filepath.Ext(baseFilename))
+}
 
+// loadExistingValues loads existing values from the output JSON file, keeping only
+// the settings of configMap.
+func loadExistingValues(configMap map[string]ItemConfig, jsonOutputFile string) (map[string]string, error) {
 	// Initialize the result map
 	existingValues := make(map[string]string)
 
@@ -214,7 +200,7 @@ func loadExistingValues(inputJSONFile, jsonOutputFile string) (map[string]string
 	}
 	defer outputFile.Close()
 
-	var outputValues map[string]string
+	var outputValues map[string]json.RawMessage
 	decoder := json.NewDecoder(outputFile)
 	if err := decoder.Decode(&outputValues); err != nil {
 		return nil, fmt.Errorf("failed to parse existing values file: %v", err)
@@ -222,30 +208,35 @@ func loadExistingValues(inputJSONFile, jsonOutputFile string) (map[string]string
 
 	// Populate existingValues with values from the output file,
 	// but only for keys that exist in the input file
-	for key := range inputConfig {
-		if value, exists := outputValues[key]; exists {
+	for key := range configMap {
+		if raw, exists := outputValues[key]; exists {
+			var value string
+			if err := json.Unmarshal(raw, &value); err != nil {
+				return nil, fmt.Errorf("failed to parse existing values file: the value of '%s' is not a string", key)
+			}
 			existingValues[key] = value
 		}
 	}
+	if raw, exists := outputValues[emptyValuesKey]; exists {
+		if _, err := parseEmptyValues(raw); err != nil {
+			return nil, fmt.Errorf("failed to parse existing values file: %s must be a list of settings", emptyValuesKey)
+		}
+		existingValues[emptyValuesKey] = string(raw)
+	}
 
 	return existingValues, nil
 }
 
-// getFileModTime gets the modification time of a file.
-func getFileModTime(filePath string) (time.Time, error) {
-	fileInfo, err := os.Stat(filePath)
-	if err != nil {
-		return time.Time{}, err
-	}
-	return fileInfo.ModTime(), nil
-}
-
 // compareConfigs checks for new or deleted settings between the input config and existing values.
+// New settings that are inactive because of their when expression are not changes.
 func compareConfigs(configMap map[string]ItemConfig, existingValues map[string]string) bool {
 	hasChanges := false
 
 	// Check for new settings.
-	for key := range configMap {
+	for key, item := range configMap {
+		if !isActive(configMap, item) {
+			continue
+		}
 		if _, exists := existingValues[key]; !exists {
 			hasChanges = true
 			break
@@ -254,6 +245,9 @@ func compareConfigs(configMap map[string]ItemConfig, existingValues map[string]s
 
 	// Check for deleted settings.
 	for key := range existingValues {
+		if key == emptyValuesKey {
+			continue
+		}
 		if _, exists := configMap[key]; !exists {
 			hasChanges = true
 			break
@@ -263,37 +257,38 @@ func compareConfigs(configMap map[string]ItemConfig, existingValues map[string]s
 	return hasChanges
 }
 
-// checkForMissingValues checks if any settings are missing values in configMap.
+// checkForMissingValues checks if any active, required settings are missing values in configMap.
 func checkForMissingValues(configMap map[string]ItemConfig) []string {
 	missingValues := []string{}
 	for key, item := range configMap {
-		if item.Default == "" {
+		if item.isMissing() && isActive(configMap, item) {
 			missingValues = append(missingValues, key)
 		}
 	}
 	return missingValues
 }
 
-// interactiveConfig handles the interactive prompt for updating settings.
-func interactiveConfig(configMap map[string]ItemConfig, inputJSONFile string, inputReader io.Reader) error {
-	keys := make([]string, 0, len(configMap))
-	for key := range configMap {
-		keys = append(keys, key)
+// activeKeys returns the ordered keys of the items that are currently active.
+func activeKeys(configMap map[string]ItemConfig) []string {
+	keys := []string{}
+	for _, key := range orderedKeys(configMap) {
+		if isActive(configMap, configMap[key]) {
+			keys = append(keys, key)
+		}
 	}
-	sort.Strings(keys)
+	return keys
+}
 
+// promptForValues shows the settings of configMap and lets the user update them,
+// calling save when they choose to save.
+func promptForValues(configMap map[string]ItemConfig, inputReader io.Reader, save func() error) error {
 	reader := bufio.NewReader(inputReader)
 
 	for {
-		// Use tabwriter for formatting.
-		writer := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
-		fmt.Fprintln(writer, "Index\tDescription\tDefault Value")
-		fmt.Fprintln(writer, "-----\t-----------\t-------------")
-		for i, key := range keys {
-			item := configMap[key]
-			fmt.Fprintf(writer, "%d\t%s\t%s\n", i+1, item.Description, item.Default)
-		}
-		writer.Flush()
+		// Changing a value can switch other settings on or off, so recompute every time.
+		keys := activeKeys(configMap)
+
+		printSettings(configMap, keys)
 
 		n := len(keys)
 		fmt.Fprintf(os.Stderr, "\nEnter a number [1..%d] to update. Press 's' to save, or 'c' to continue: ", n)
@@ -306,7 +301,7 @@ func interactiveConfig(configMap map[string]ItemConfig, inputJSONFile string, in
 		switch strings.ToLower(input) {
 		case "s":
 			// Save the updated configuration.
-			if err := saveConfig(inputJSONFile, configMap); err != nil {
+			if err := save(); err != nil {
 				return fmt.Errorf("failed to save configuration: %v", err)
 			}
 			fmt.Fprintln(os.Stderr, "Configuration saved.")
@@ -322,14 +317,20 @@ func interactiveConfig(configMap map[string]ItemConfig, inputJSONFile string, in
 			// Update the selected item.
 			key := keys[index-1]
 			item := configMap[key]
-			fmt.Fprint(os.Stderr, "Enter new default value (leave empty to keep current value): ")
+			fmt.Fprintf(os.Stderr, "Enter new default value (leave empty to keep current value, %s to clear it): ", emptyValueInput)
 			newValue, err := reader.ReadString('\n')
 			if err != nil {
 				return fmt.Errorf("failed to read input: %v", err)
 			}
 			newValue = strings.TrimSpace(newValue)
-			if newValue != "" {
+			if newValue == emptyValueInput {
+				item.Default = ""
+				item.EmptyIsValid = true
+				configMap[key] = item
+				fmt.Fprintf(os.Stderr, "Default value for '%s' cleared\n", item.Description)
+			} else if newValue != "" {
 				item.Default = newValue
+				item.EmptyIsValid = false
 				configMap[key] = item
 				fmt.Fprintf(os.Stderr, "Default value for '%s' updated to '%s'\n", item.Description, newValue)
 			} else {
@@ -339,55 +340,108 @@ func interactiveConfig(configMap map[string]ItemConfig, inputJSONFile string, in
 	}
 }
 
-// saveConfig saves the updated configuration to the appropriate files.
-func saveConfig(inputJSONFile string, configMap map[string]ItemConfig) error {
-	// Use getOutputFilePaths to determine the output file paths
-	jsonOutputFile, envOutputFile, err := GetOutputFilePaths(inputJSONFile)
-	if err != nil {
-		return err
+// printSettings lists the settings of keys on stderr in the promptStyle setting's
+// style: a table, or plain lines that screen readers and narrow terminals cope with.
+func printSettings(configMap map[string]ItemConfig, keys []string) {
+	if activeSettings.PromptStyle == PromptStylePlain {
+		group := ""
+		for i, key := range keys {
+			item := configMap[key]
+			if heading, changed := groupHeading(group, item); changed {
+				group = item.Group
+				fmt.Fprintf(os.Stderr, "%s:\n", heading)
+			}
+			fmt.Fprintf(os.Stderr, "%d) %s: %s\n", i+1, item.Description, item.Default)
+		}
+		return
 	}
 
-	// Prepare data for .json file (simple key-value pairs)
-	outputValues := make(map[string]string)
-	for key, item := range configMap {
-		outputValues[key] = item.Default
+	// Use tabwriter for formatting.
+	writer := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "Index\tDescription\tDefault Value")
+	fmt.Fprintln(writer, "-----\t-----------\t-------------")
+	group := ""
+	for i, key := range keys {
+		item := configMap[key]
+		if heading, changed := groupHeading(group, item); changed {
+			group = item.Group
+			fmt.Fprintf(writer, "\t[%s]\t\n", heading)
+		}
+		fmt.Fprintf(writer, "%d\t%s\t%s\n", i+1, item.Description, item.Default)
 	}
+	writer.Flush()
+}
 
-	// Write to the .json file
-	jsonFile, err := os.Create(jsonOutputFile)
-	if err != nil {
-		return fmt.Errorf("failed to create JSON output file: %v", err)
+// saveConfigTo saves the configuration to the given output files.  Files whose
+// content is unchanged are not rewritten, so collecting values that are up to date
+// doesn't touch the disk.
+func saveConfigTo(jsonOutputFile, envOutputFile string, configMap map[string]ItemConfig) error {
+	// Prepare data for .json file (simple key-value pairs) in input file order
+	keys := orderedKeys(configMap)
+	outputValues := make(map[string]interface{})
+	for _, key := range keys {
+		outputValues[key] = configMap[key].Default
+	}
+	if emptyValues := formatEmptyValues(configMap); len(emptyValues) > 0 {
+		outputValues[emptyValuesKey] = emptyValues
+		keys = append(keys, emptyValuesKey)
 	}
-	defer jsonFile.Close()
 
-	jsonEncoder := json.NewEncoder(jsonFile)
-	jsonEncoder.SetIndent("", "    ") // Format JSON with indentation
-	if err := jsonEncoder.Encode(outputValues); err != nil {
+	// Write to the .json file
+	jsonContent, err := marshalOrderedValues(keys, outputValues)
+	if err != nil {
 		return fmt.Errorf("failed to write JSON output file: %v", err)
 	}
+	if err := writeFileIfChanged(jsonOutputFile, jsonContent); err != nil {
+		return fmt.Errorf("failed to create JSON output file: %v", err)
+	}
 
 	// Prepare data for .env file
 	var envLines []string
-	for _, item := range configMap {
-		if item.TempEnvironmentVariableName != "" {
-			line := fmt.Sprintf("%s=%s", item.TempEnvironmentVariableName, item.Default)
-			envLines = append(envLines, line)
-		}
+	for _, envVar := range envVars(configMap) {
+		envLines = append(envLines, envVar.String())
 	}
 
 	// Write to the .env file if there are any environment variables
-	if len(envLines) > 0 {
-		envFile, err := os.Create(envOutputFile)
-		if err != nil {
-			return fmt.Errorf("failed to create env output file: %v", err)
-		}
-		defer envFile.Close()
-
+	if len(envLines) > 0 && activeSettings.writesEnvFile() {
 		envContent := strings.Join(envLines, "\n")
-		if _, err := envFile.WriteString(envContent); err != nil {
+		if err := writeFileIfChanged(envOutputFile, []byte(envContent)); err != nil {
 			return fmt.Errorf("failed to write env output file: %v", err)
 		}
 	}
 
 	return nil
 }
+
+// valuesFileMode is the mode of the values and env files.  They hold secrets, so only
+// their owner may read them.
+const valuesFileMode os.FileMode = 0600
+
+// writeFileIfChanged writes content to path unless the file already holds exactly
+// that content.  The file is replaced in one step, so readers that don't take the
+// project's lock, like the prompt hook, never see it half written.
+func writeFileIfChanged(path string, content []byte) error {
+	if existing, err := os.ReadFile(path); err == nil && bytes.Equal(existing, content) {
+		// Older versions let everyone read the files.
+		if info, err := os.Stat(path); err == nil && info.Mode().Perm() != valuesFileMode {
+			return os.Chmod(path, valuesFileMode)
+		}
+		return nil
+	}
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.Write(content); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(file.Name(), valuesFileMode); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
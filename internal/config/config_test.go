@@ -95,7 +95,13 @@ func TestSaveConfig(t *testing.T) {
 	}
 
 	// Test saving the config
-	err = saveConfig(inputJSONFile, configMap)
+	s, err := newSession(inputJSONFile)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	s.configMap = configMap
+	err = s.save()
+	s.close()
 	if err != nil {
 		t.Errorf("Expected no error, got %v", err)
 	}
@@ -211,29 +217,34 @@ func TestCheckForMissingValues(t *testing.T) {
 }
 
 func TestInteractiveConfig(t *testing.T) {
-	// Prepare configMap with one item
-	configMap := map[string]ItemConfig{
-		"key1": {
-			Description: "Test key 1",
-			Default:     "default1",
-		},
+	dir := t.TempDir()
+	setStoreEnv(t, dir)
+
+	// Prepare an input file with one item
+	inputJSONFile := filepath.Join(dir, "interactive-config.json")
+	if err := os.WriteFile(inputJSONFile, []byte(`{"key1": {"description": "Test key 1", "default": "default1"}}`), 0644); err != nil {
+		t.Fatalf("Failed to write input JSON file: %v", err)
+	}
+	s, err := newSession(inputJSONFile)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
 	}
+	defer s.close()
 
 	// Simulate user input: Update value and then save
 	userInput := bytes.NewBufferString("1\nnewvalue\ns\nc\n")
 
-	// Mock inputJSONFile path
-	inputJSONFile := "/dev/null"
-
-	// Run interactiveConfig
-	err := interactiveConfig(configMap, inputJSONFile, userInput)
+	err = s.prompt(userInput)
 	if err != nil {
 		t.Errorf("Expected no error, got %v", err)
 	}
 
-	// Verify that the value was updated
-	if configMap["key1"].Default != "newvalue" {
-		t.Errorf("Expected 'key1' default to be 'newvalue', got '%s'", configMap["key1"].Default)
+	// Verify that the value was updated and saved
+	if s.configMap["key1"].Default != "newvalue" {
+		t.Errorf("Expected 'key1' default to be 'newvalue', got '%s'", s.configMap["key1"].Default)
+	}
+	if values := readValues(t, inputJSONFile); values["key1"] != "newvalue" {
+		t.Errorf("Expected 'key1' to be saved as 'newvalue', got %v", values)
 	}
 }
 
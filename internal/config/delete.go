@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"strings"
 )
 
@@ -21,6 +22,13 @@ func DeleteConfig(inputJSONFile string, silent bool, inputReader io.Reader) erro
 		return err
 	}
 
+	// Keep other processes from collecting the values while they are deleted.
+	lock, err := lockProject(filepath.Dir(jsonOutputFile), activeSettings.lockTimeout())
+	if err != nil {
+		return err
+	}
+	defer lock.unlock()
+
 	// Collect the files to delete
 	filesToDelete := []string{}
 
@@ -0,0 +1,130 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// envrcName is the file direnv loads when entering a directory.
+const envrcName = ".envrc"
+
+// DirenvFunction returns the direnv stdlib function use_repo_config, which runs
+// executable to export the values collected for an input file.  An .envrc calls it
+// with "use repo_config [input file]"; without a file the input file is found the way
+// collect finds it.
+func DirenvFunction(executable string) string {
+	return fmt.Sprintf(`# use repo_config [input file]
+#
+# Exports the values repo-config collected for the input file, and reloads when the
+# input file, the files it includes or its values change.
+use_repo_config() {
+  local statements
+  statements="$(%s direnv-export ${1:+--json "$1"})" || return
+  eval "$statements"
+}
+`, quotePOSIX(executable))
+}
+
+// EnvrcSnippet returns the lines of an .envrc in envrcDir that load the values of
+// inputFile.  They define use_repo_config unless direnvrc already did, so the .envrc
+// works for everyone who has repo-config installed.
+func EnvrcSnippet(envrcDir, inputFile string) (string, error) {
+	name, err := envrcInputName(envrcDir, inputFile)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(`# Load the values repo-config collected for %s.
+has use_repo_config || eval "$(repo-config direnv)"
+%s
+`, name, envrcUseLine(name)), nil
+}
+
+// envrcInputName returns inputFile relative to envrcDir, as .envrc files are checked
+// in and must work wherever the repo is cloned.
+func envrcInputName(envrcDir, inputFile string) (string, error) {
+	absDir, err := filepath.Abs(envrcDir)
+	if err != nil {
+		return "", err
+	}
+	absInputFile, err := filepath.Abs(inputFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to get absolute path of input JSON file: %v", err)
+	}
+	name, err := filepath.Rel(absDir, absInputFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to find '%s' from '%s': %v", inputFile, envrcDir, err)
+	}
+	return filepath.ToSlash(name), nil
+}
+
+// envrcUseLine returns the line of an .envrc that loads the values of inputFile.
+func envrcUseLine(inputFile string) string {
+	return "use repo_config " + quotePOSIX(inputFile)
+}
+
+// WriteEnvrc adds the lines of EnvrcSnippet to the .envrc in the directory of
+// inputFile, creating it if needed, and returns its path.  An .envrc that loads
+// inputFile already is left alone, and other lines in it are kept.
+func WriteEnvrc(inputFile string) (string, error) {
+	absInputFile, err := filepath.Abs(inputFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to get absolute path of input JSON file: %v", err)
+	}
+	envrcDir := filepath.Dir(absInputFile)
+	envrcFile := filepath.Join(envrcDir, envrcName)
+	snippet, err := EnvrcSnippet(envrcDir, absInputFile)
+	if err != nil {
+		return "", err
+	}
+
+	content, err := os.ReadFile(envrcFile)
+	if err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to read '%s': %v", envrcFile, err)
+	}
+	useLine := envrcUseLine(filepath.Base(absInputFile))
+	for _, line := range strings.Split(string(content), "\n") {
+		if strings.TrimSpace(line) == useLine {
+			return envrcFile, nil
+		}
+	}
+
+	existing := string(content)
+	if existing != "" && !strings.HasSuffix(existing, "\n") {
+		existing += "\n"
+	}
+	if existing != "" {
+		existing += "\n"
+	}
+	if err := os.WriteFile(envrcFile, []byte(existing+snippet), 0644); err != nil {
+		return "", fmt.Errorf("failed to write '%s': %v", envrcFile, err)
+	}
+	return envrcFile, nil
+}
+
+// DirenvExport returns the bash statements use_repo_config evaluates for inputFile:
+// watch_file for the input file, the files it includes and its values file, so direnv
+// reloads when any of them changes, followed by exports of the values.  Like the
+// prompt hook it never prompts, as direnv has no terminal to prompt on: values that
+// haven't been collected are left out, with a note on stderr.  It doesn't wait for
+// the project's lock or write to the store either.
+func DirenvExport(inputFile string) (string, error) {
+	absInputFile, err := filepath.Abs(inputFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to get absolute path of input JSON file: %v", err)
+	}
+
+	watched := watchedFiles(absInputFile)
+	quoted := make([]string, len(watched))
+	for i, file := range watched {
+		quoted[i] = quotePOSIX(file)
+	}
+
+	state := hookState{InputFile: absInputFile}
+	exports, err := FormatExports(ShellBash, loadHookVars(&state))
+	if err != nil {
+		return "", err
+	}
+	return "watch_file " + strings.Join(quoted, " ") + "\n" + exports, nil
+}
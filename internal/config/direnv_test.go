@@ -0,0 +1,111 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteEnvrc(t *testing.T) {
+	dir := t.TempDir()
+	inputFile := filepath.Join(dir, "repo-config.json")
+	writeFiles(t, dir, map[string]string{".envrc": "export FOO=bar"})
+
+	envrcFile, err := WriteEnvrc(inputFile)
+	if err != nil {
+		t.Fatalf("Failed to write .envrc: %v", err)
+	}
+	expected := "export FOO=bar\n\n# Load the values repo-config collected for repo-config.json.\n" +
+		"has use_repo_config || eval \"$(repo-config direnv)\"\nuse repo_config 'repo-config.json'\n"
+	content, _ := os.ReadFile(envrcFile)
+	if string(content) != expected {
+		t.Errorf("Expected .envrc %q, got %q", expected, content)
+	}
+
+	// Writing it again leaves it alone.
+	if _, err := WriteEnvrc(inputFile); err != nil {
+		t.Fatalf("Failed to write .envrc again: %v", err)
+	}
+	if content, _ := os.ReadFile(envrcFile); string(content) != expected {
+		t.Errorf("Expected .envrc to be unchanged, got %q", content)
+	}
+
+	snippet, err := EnvrcSnippet(filepath.Dir(dir), inputFile)
+	if err != nil || !strings.HasSuffix(snippet, "use repo_config '"+filepath.Base(dir)+"/repo-config.json'\n") {
+		t.Errorf("Expected the input file relative to the .envrc, got %q (%v)", snippet, err)
+	}
+}
+
+func TestDirenvExport(t *testing.T) {
+	inputFile := newSessionTestRepo(t)
+	writeFiles(t, filepath.Dir(inputFile), map[string]string{
+		filepath.Base(inputFile): "$include: [shared.yaml]\nregion:\n  default: westus\n  tempEnvironmentVariableName: REGION\n",
+		"shared.yaml":            "owner:\n  default: me\n  tempEnvironmentVariableName: OWNER\n",
+	})
+	jsonOutputFile, _, err := GetOutputFilePaths(inputFile)
+	if err != nil {
+		t.Fatalf("Failed to get output file paths: %v", err)
+	}
+	writeFiles(t, filepath.Dir(jsonOutputFile), map[string]string{filepath.Base(jsonOutputFile): `{"region": "eastus"}`})
+
+	statements, err := DirenvExport(inputFile)
+	if err != nil {
+		t.Fatalf("Failed to export: %v", err)
+	}
+	realInputFile, _ := filepath.EvalSymlinks(inputFile)
+	lines := strings.Split(statements, "\n")
+	expected := "watch_file " + quotePOSIX(realInputFile) + " " + quotePOSIX(filepath.Join(filepath.Dir(realInputFile), "shared.yaml")) + " " + quotePOSIX(jsonOutputFile)
+	if lines[0] != expected {
+		t.Errorf("Expected %q, got %q", expected, lines[0])
+	}
+	if exports := strings.Join(lines[1:], "\n"); exports != "export OWNER='me'\nexport REGION='eastus'\n" {
+		t.Errorf("Expected the values to be exported, got %q", exports)
+	}
+
+	// A broken input file is still watched, so fixing it reloads.
+	writeFiles(t, filepath.Dir(inputFile), map[string]string{filepath.Base(inputFile): "region: ["})
+	statements, _ = DirenvExport(inputFile)
+	if !strings.HasPrefix(statements, "watch_file "+quotePOSIX(inputFile)+" ") {
+		t.Errorf("Expected the broken input file to be watched, got %q", statements)
+	}
+}
+
+func TestDirenvExportDoesNotWaitForCollect(t *testing.T) {
+	inputFile := newSessionTestRepo(t)
+	jsonOutputFile, _, err := GetOutputFilePaths(inputFile)
+	if err != nil {
+		t.Fatalf("Failed to get output file paths: %v", err)
+	}
+	lock, err := lockProject(filepath.Dir(jsonOutputFile), time.Second)
+	if err != nil {
+		t.Fatalf("Failed to lock project: %v", err)
+	}
+	defer lock.unlock()
+	settings := defaultSettings()
+	settings.LockTimeout = "1m"
+	UseSettings(settings)
+	defer UseSettings(defaultSettings())
+
+	start := time.Now()
+	statements, err := DirenvExport(inputFile)
+	if err != nil || !strings.Contains(statements, "export REGION='westus'\n") {
+		t.Errorf("Expected the values to be exported, got %q (%v)", statements, err)
+	}
+	if time.Since(start) > 10*time.Second {
+		t.Errorf("Expected direnv not to wait for the lock, took %s", time.Since(start))
+	}
+
+	// A project that was never collected gets no directory in the store.
+	repo := newTestRepo(t)
+	writeFiles(t, repo, map[string]string{"repo-config.json": `{"region": {"default": "westus"}}`})
+	storeDir := filepath.Dir(filepath.Dir(jsonOutputFile))
+	entries, _ := os.ReadDir(storeDir)
+	if _, err := DirenvExport(filepath.Join(repo, "repo-config.json")); err != nil {
+		t.Fatalf("Failed to export: %v", err)
+	}
+	if after, _ := os.ReadDir(storeDir); len(after) != len(entries) {
+		t.Errorf("Expected direnv not to write to the store, got %v", after)
+	}
+}
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"fmt"
+	"os"
+)
+
+// EnvVar is an environment variable defined by a setting.
+type EnvVar struct {
+	Name  string
+	Value string
+}
+
+// String returns the variable as NAME=value, the way it is written to the env file
+// and passed to processes.
+func (v EnvVar) String() string {
+	return fmt.Sprintf("%s=%s", v.Name, v.Value)
+}
+
+// envVars returns the environment variables defined by the active settings of
+// configMap, in input file order.
+func envVars(configMap map[string]ItemConfig) []EnvVar {
+	vars := []EnvVar{}
+	for _, key := range orderedKeys(configMap) {
+		item := configMap[key]
+		if name := envVarName(key, item); name != "" && isActive(configMap, item) {
+			vars = append(vars, EnvVar{Name: name, Value: item.Default})
+		}
+	}
+	return vars
+}
+
+// CollectEnv collects the values of inputJSONFile like CollectConfig in silent mode,
+// prompting only for missing values, and returns the environment variables they
+// define.
+func CollectEnv(inputJSONFile string) ([]EnvVar, error) {
+	s, err := newSession(inputJSONFile)
+	if err != nil {
+		return nil, err
+	}
+	defer s.close()
+	if err := s.collect(true, os.Stdin); err != nil {
+		return nil, err
+	}
+	return envVars(s.configMap), nil
+}
@@ -0,0 +1,19 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCollectEnv(t *testing.T) {
+	inputFile := newSessionTestRepo(t)
+	vars, err := CollectEnv(inputFile)
+	if err != nil {
+		t.Fatalf("Failed to collect env: %v", err)
+	}
+	values := readValues(t, inputFile)
+	expected := []EnvVar{{"REGION", "westus"}, {"DB_PASSWORD", values["dbPassword"]}}
+	if !reflect.DeepEqual(vars, expected) {
+		t.Errorf("Expected %v, got %v", expected, vars)
+	}
+}
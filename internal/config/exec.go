@@ -0,0 +1,52 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+)
+
+// RunCommand runs command with vars added to the environment of this process, and
+// returns its exit code.  Signals this process receives are forwarded to it while it
+// runs, so interrupting or terminating repo-config stops the command too.  Only the
+// command sees vars; this process's environment is unchanged.
+func RunCommand(command []string, vars []EnvVar) (int, error) {
+	if len(command) == 0 {
+		return 0, fmt.Errorf("no command to run")
+	}
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = os.Environ()
+	for _, v := range vars {
+		// exec.Cmd uses the last value of duplicate names, so these win.
+		cmd.Env = append(cmd.Env, v.String())
+	}
+
+	// Catch the signals before starting so none is missed.
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, forwardedSignals...)
+	if err := cmd.Start(); err != nil {
+		signal.Stop(signals)
+		return 0, fmt.Errorf("failed to start '%s': %v", command[0], err)
+	}
+	go func() {
+		for sig := range signals {
+			_ = cmd.Process.Signal(sig)
+		}
+	}()
+
+	err := cmd.Wait()
+	signal.Stop(signals)
+	close(signals)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitCode(exitErr.ProcessState), nil
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to run '%s': %v", command[0], err)
+	}
+	return 0, nil
+}
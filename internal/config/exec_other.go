@@ -0,0 +1,13 @@
+//go:build !unix
+
+package config
+
+import "os"
+
+// forwardedSignals are the signals RunCommand passes on to the command.
+var forwardedSignals = []os.Signal{os.Interrupt}
+
+// exitCode returns the exit code of a finished process.
+func exitCode(state *os.ProcessState) int {
+	return state.ExitCode()
+}
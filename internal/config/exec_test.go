@@ -0,0 +1,63 @@
+//go:build unix
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRunCommandEnvironmentAndExitCode(t *testing.T) {
+	dir := t.TempDir()
+	output := filepath.Join(dir, "output")
+	os.Setenv("REPO_CONFIG_TEST_OVERRIDDEN", "shell")
+	defer os.Unsetenv("REPO_CONFIG_TEST_OVERRIDDEN")
+
+	vars := []EnvVar{{"REPO_CONFIG_TEST_OVERRIDDEN", "value"}, {"REPO_CONFIG_TEST_QUOTED", "a 'b' $c"}}
+	code, err := RunCommand([]string{"sh", "-c", `printf '%s|%s' "$REPO_CONFIG_TEST_OVERRIDDEN" "$REPO_CONFIG_TEST_QUOTED" > "$1"; exit 3`, "sh", output}, vars)
+	if err != nil {
+		t.Fatalf("Failed to run command: %v", err)
+	}
+	if code != 3 {
+		t.Errorf("Expected exit code 3, got %d", code)
+	}
+	if content, err := os.ReadFile(output); err != nil || string(content) != "value|a 'b' $c" {
+		t.Errorf("Expected the values in the command's environment, got %q (%v)", content, err)
+	}
+	if value := os.Getenv("REPO_CONFIG_TEST_QUOTED"); value != "" {
+		t.Errorf("Expected this process's environment to be unchanged, got %s", value)
+	}
+
+	if _, err := RunCommand([]string{filepath.Join(dir, "missing")}, nil); err == nil {
+		t.Error("Expected an error for a missing command")
+	}
+}
+
+func TestRunCommandForwardsSignals(t *testing.T) {
+	ready := filepath.Join(t.TempDir(), "ready")
+	go func() {
+		for i := 0; i < 100; i++ {
+			if _, err := os.Stat(ready); err == nil {
+				syscall.Kill(os.Getpid(), syscall.SIGTERM)
+				return
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	}()
+
+	code, err := RunCommand([]string{"sh", "-c", `trap 'exit 7' TERM; touch "$1"; while :; do sleep 0.05; done`, "sh", ready}, nil)
+	if err != nil {
+		t.Fatalf("Failed to run command: %v", err)
+	}
+	if code != 7 {
+		t.Errorf("Expected the command to exit from its TERM trap with 7, got %d", code)
+	}
+
+	code, err = RunCommand([]string{"sh", "-c", `kill -KILL $$`}, nil)
+	if err != nil || code != 128+int(syscall.SIGKILL) {
+		t.Errorf("Expected exit code %d for a killed command, got %d (%v)", 128+int(syscall.SIGKILL), code, err)
+	}
+}
@@ -0,0 +1,293 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Input file formats.
+const (
+	formatJSON = "json"
+	formatYAML = "yaml"
+)
+
+// detectFormat decides how to parse an input file.  The extension wins when it is
+// a known one; otherwise a document that starts with '{' (after comments) is JSON
+// and anything else is treated as YAML.
+func detectFormat(path string, data []byte) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return formatYAML
+	case ".json", ".jsonc":
+		return formatJSON
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(stripJSONC(data)), []byte("{")) {
+		return formatJSON
+	}
+	return formatYAML
+}
+
+// toJSON converts the contents of an input file to strict JSON so that every format
+// is decoded by the same code with the same ItemConfig semantics.
+func toJSON(path string, data []byte) ([]byte, error) {
+	if detectFormat(path, data) == formatYAML {
+		converted, err := yamlToJSON(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse YAML file: %v", err)
+		}
+		return converted, nil
+	}
+	return stripJSONC(data), nil
+}
+
+// stripJSONC removes // and /* */ comments and trailing commas from JSON with
+// comments.  Comments are replaced with spaces (newlines are kept) so that parse
+// errors still point at the right place.  Strict JSON passes through unchanged.
+func stripJSONC(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	inString := false
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case inString:
+			out = append(out, c)
+			if c == '\\' && i+1 < len(data) {
+				i++
+				out = append(out, data[i])
+			} else if c == '"' {
+				inString = false
+			}
+		case c == '"':
+			inString = true
+			out = append(out, c)
+		case c == '/' && i+1 < len(data) && data[i+1] == '/':
+			for i < len(data) && data[i] != '\n' {
+				out = append(out, ' ')
+				i++
+			}
+			if i < len(data) {
+				out = append(out, '\n')
+			}
+		case c == '/' && i+1 < len(data) && data[i+1] == '*':
+			out = append(out, ' ', ' ')
+			i += 2
+			for i < len(data) && !(data[i] == '*' && i+1 < len(data) && data[i+1] == '/') {
+				if data[i] == '\n' {
+					out = append(out, '\n')
+				} else {
+					out = append(out, ' ')
+				}
+				i++
+			}
+			if i < len(data) {
+				out = append(out, ' ', ' ')
+				i++
+			}
+		default:
+			out = append(out, c)
+		}
+	}
+	return removeTrailingCommas(out)
+}
+
+// removeTrailingCommas blanks out commas that are only followed by whitespace and a
+// closing brace or bracket.  It expects comments to have been stripped already.
+func removeTrailingCommas(data []byte) []byte {
+	inString := false
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		if inString {
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				inString = false
+			}
+			continue
+		}
+		if c == '"' {
+			inString = true
+			continue
+		}
+		if c != ',' {
+			continue
+		}
+		j := i + 1
+		for j < len(data) && (data[j] == ' ' || data[j] == '\t' || data[j] == '\n' || data[j] == '\r') {
+			j++
+		}
+		if j < len(data) && (data[j] == '}' || data[j] == ']') {
+			data[i] = ' '
+		}
+	}
+	return data
+}
+
+// yamlLevel is what a YAML node is in an input file, which decides how its scalars
+// are converted.
+type yamlLevel int
+
+const (
+	yamlTop      yamlLevel = iota // the document: settings and directives
+	yamlDefs                      // the $defs mapping, whose values are items
+	yamlItem                      // a setting or definition
+	yamlGenerate                  // the generate field of an item
+	yamlNative                    // a field whose scalars keep their type
+	yamlText                      // anything else, whose scalars are strings
+)
+
+// yamlNativeFields are the fields of an item, and of its generate field, whose YAML
+// scalars keep their type.  Every other scalar becomes a string as written, so
+// "default: 8080" is the text 8080 and dates aren't reformatted.
+var yamlNativeFields = map[yamlLevel]map[string]bool{
+	yamlItem:     {"requiredAsEnv": true, "required": true, "secret": true},
+	yamlGenerate: {"length": true},
+}
+
+// childLevel returns the level of the value of key in a mapping at level.
+func (level yamlLevel) childLevel(key string) yamlLevel {
+	switch {
+	case level == yamlTop && key == defsKey:
+		return yamlDefs
+	case level == yamlTop && strings.HasPrefix(key, "$"):
+		return yamlText
+	case level == yamlTop || level == yamlDefs:
+		return yamlItem
+	case level == yamlItem && key == "generate":
+		return yamlGenerate
+	case yamlNativeFields[level][key]:
+		return yamlNative
+	}
+	return yamlText
+}
+
+// yamlToJSON converts a YAML document to JSON, keeping mapping keys in order.
+func yamlToJSON(data []byte) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Kind == 0 {
+		return []byte("{}"), nil
+	}
+	var buf bytes.Buffer
+	if err := writeYAMLNodeAsJSON(&buf, &doc, yamlTop); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeYAMLNodeAsJSON writes node, which is at level in the input file, to buf as
+// JSON.  Scalars are strings unless they are null or level is yamlNative.
+func writeYAMLNodeAsJSON(buf *bytes.Buffer, node *yaml.Node, level yamlLevel) error {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		return writeYAMLNodeAsJSON(buf, node.Content[0], level)
+	case yaml.AliasNode:
+		return writeYAMLNodeAsJSON(buf, node.Alias, level)
+	case yaml.MappingNode:
+		pairs, err := yamlMappingPairs(node)
+		if err != nil {
+			return err
+		}
+		buf.WriteString("{")
+		for i, pair := range pairs {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			key, err := json.Marshal(pair[0].Value)
+			if err != nil {
+				return err
+			}
+			buf.Write(key)
+			buf.WriteString(":")
+			if err := writeYAMLNodeAsJSON(buf, pair[1], level.childLevel(pair[0].Value)); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("}")
+	case yaml.SequenceNode:
+		buf.WriteString("[")
+		for i, child := range node.Content {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			if err := writeYAMLNodeAsJSON(buf, child, yamlText); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("]")
+	case yaml.ScalarNode:
+		var value interface{} = node.Value
+		if node.ShortTag() == "!!null" {
+			value = nil
+		} else if level == yamlNative {
+			if err := node.Decode(&value); err != nil {
+				return fmt.Errorf("line %d: %v", node.Line, err)
+			}
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("line %d: %v", node.Line, err)
+		}
+		buf.Write(encoded)
+	default:
+		return fmt.Errorf("line %d: unsupported YAML node", node.Line)
+	}
+	return nil
+}
+
+// yamlMappingPairs returns the key and value nodes of a mapping, with the mappings
+// its merge keys ("<<: *base") name merged in where the merge key is.  Keys the
+// mapping sets itself win over merged ones, and earlier merged mappings win over
+// later ones.
+func yamlMappingPairs(node *yaml.Node) ([][2]*yaml.Node, error) {
+	own := map[string]bool{}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].ShortTag() != "!!merge" {
+			own[node.Content[i].Value] = true
+		}
+	}
+	pairs := [][2]*yaml.Node{}
+	merged := map[string]bool{}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if key.ShortTag() != "!!merge" {
+			pairs = append(pairs, [2]*yaml.Node{key, value})
+			continue
+		}
+		sources := []*yaml.Node{value}
+		if resolveYAMLAlias(value).Kind == yaml.SequenceNode {
+			sources = resolveYAMLAlias(value).Content
+		}
+		for _, source := range sources {
+			source = resolveYAMLAlias(source)
+			if source.Kind != yaml.MappingNode {
+				return nil, fmt.Errorf("line %d: << must merge a mapping or a list of mappings", key.Line)
+			}
+			sourcePairs, err := yamlMappingPairs(source)
+			if err != nil {
+				return nil, err
+			}
+			for _, pair := range sourcePairs {
+				if name := pair[0].Value; !own[name] && !merged[name] {
+					merged[name] = true
+					pairs = append(pairs, pair)
+				}
+			}
+		}
+	}
+	return pairs, nil
+}
+
+// resolveYAMLAlias returns the node an alias refers to, or node itself.
+func resolveYAMLAlias(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	return node
+}
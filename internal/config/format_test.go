@@ -0,0 +1,198 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStripJSONC(t *testing.T) {
+	input := `{
+		// a line comment
+		"url": "http://example.com/path", /* block
+		comment */ "quote": "say \"//hi\"",
+		"list": [1, 2,],
+	}`
+	configText := string(stripJSONC([]byte(input)))
+
+	var value map[string]interface{}
+	if err := json.Unmarshal([]byte(configText), &value); err != nil {
+		t.Fatalf("Expected valid JSON, got %v:\n%s", err, configText)
+	}
+	if value["url"] != "http://example.com/path" || value["quote"] != `say "//hi"` {
+		t.Errorf("Expected strings to be untouched, got %v", value)
+	}
+}
+
+func TestLoadConfigFileFormats(t *testing.T) {
+	dir, err := os.MkdirTemp("", "config_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"settings.jsonc": `{
+			// where the Azure resources live
+			"azureLocation": {
+				"description": "the location for your Azure Datacenter",
+				"default": "uswest3",
+				"requiredAsEnv": true,
+			},
+			"username": {"description": "the username", "tempEnvironmentVariableName": "USERNAME"},
+		}`,
+		"settings.yaml": `
+# where the Azure resources live
+azureLocation:
+  description: the location for your Azure Datacenter
+  default: uswest3
+  requiredAsEnv: true
+username:
+  description: the username
+  tempEnvironmentVariableName: USERNAME
+`,
+		// no extension: detected from the content
+		"settings-yaml": `
+azureLocation:
+  description: the location for your Azure Datacenter
+  default: uswest3
+  requiredAsEnv: true
+username:
+  description: the username
+  tempEnvironmentVariableName: USERNAME
+`,
+	}
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		configMap, err := loadConfigFile(path)
+		if err != nil {
+			t.Errorf("%s: expected no error, got %v", name, err)
+			continue
+		}
+		if keys := orderedKeys(configMap); !reflect.DeepEqual(keys, []string{"azureLocation", "username"}) {
+			t.Errorf("%s: expected keys in declaration order, got %v", name, keys)
+		}
+		location := configMap["azureLocation"]
+		if location.Default != "uswest3" || !location.RequiredAsEnv {
+			t.Errorf("%s: unexpected azureLocation %+v", name, location)
+		}
+		if configMap["username"].TempEnvironmentVariableName != "USERNAME" {
+			t.Errorf("%s: unexpected username %+v", name, configMap["username"])
+		}
+	}
+
+	invalidYAMLPath := filepath.Join(dir, "invalid.yml")
+	if err := os.WriteFile(invalidYAMLPath, []byte("key: [unclosed"), 0644); err != nil {
+		t.Fatalf("Failed to write invalid YAML file: %v", err)
+	}
+	if _, err := loadConfigFile(invalidYAMLPath); err == nil {
+		t.Error("Expected error for invalid YAML, got nil")
+	}
+}
+
+func TestLoadConfigFileYAMLScalars(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"repo-config.yaml": `
+port:
+  description: the port
+  default: 8080
+  required: false
+debug:
+  default: true
+since:
+  default: 2024-01-02
+version:
+  default: 1.10
+unset:
+  default: ~
+token:
+  generate: {kind: hex, length: 16}
+  shellscript: make-token
+  env:
+    RETRIES: 3
+`})
+
+	configMap, err := loadConfigFile(filepath.Join(dir, "repo-config.yaml"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for key, expected := range map[string]string{"port": "8080", "debug": "true", "since": "2024-01-02", "version": "1.10", "unset": ""} {
+		if configMap[key].Default != expected {
+			t.Errorf("Expected %s to be %q as written, got %q", key, expected, configMap[key].Default)
+		}
+	}
+	if configMap["port"].isRequired() || configMap["token"].Generate.Length != 16 || configMap["token"].Env["RETRIES"] != "3" {
+		t.Errorf("Expected required, length and env to be read, got %+v and %+v", configMap["port"], configMap["token"])
+	}
+}
+
+func TestLoadConfigFileYAMLMergeKeys(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"repo-config.yaml": `
+$defs:
+  azure: &azure
+    description: Azure setting
+    required: false
+    shellscript: az config get defaults.location
+    env: {length: 5}
+location:
+  <<: *azure
+  default: westus
+group:
+  <<: [*azure, {description: ignored, group: azure}]
+  description: Resource group
+`})
+
+	configMap, err := loadConfigFile(filepath.Join(dir, "repo-config.yaml"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(configMap) != 2 {
+		t.Errorf("Expected only location and group to be settings, got %v", configMap)
+	}
+	location, group := configMap["location"], configMap["group"]
+	if location.Description != "Azure setting" || location.Default != "westus" || location.isRequired() || location.Env["length"] != "5" {
+		t.Errorf("Expected location to merge the azure definition, got %+v", location)
+	}
+	if group.Description != "Resource group" || group.Group != "azure" || group.isRequired() {
+		t.Errorf("Expected group's own fields to win over merged ones, got %+v", group)
+	}
+
+	writeFiles(t, dir, map[string]string{"repo-config.yaml": "location:\n  <<: westus\n"})
+	if _, err := loadConfigFile(filepath.Join(dir, "repo-config.yaml")); err == nil || !strings.Contains(err.Error(), "<< must merge a mapping") {
+		t.Errorf("Expected an error merging a scalar, got %v", err)
+	}
+}
+
+func TestOutputNamesIgnoreInputExtension(t *testing.T) {
+	dir, err := os.MkdirTemp("", "config_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	originalHome := os.Getenv("HOME")
+	os.Setenv("HOME", dir)
+	defer os.Setenv("HOME", originalHome)
+
+	jsonOutput, envOutput, err := GetOutputFilePaths(filepath.Join(dir, "settings.json"))
+	if err != nil {
+		t.Fatalf("Failed to get output file paths: %v", err)
+	}
+	for _, name := range []string{"settings.jsonc", "settings.yaml", "settings.yml"} {
+		otherJSON, otherEnv, err := GetOutputFilePaths(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("Failed to get output file paths: %v", err)
+		}
+		if otherJSON != jsonOutput || otherEnv != envOutput {
+			t.Errorf("%s: expected %s and %s, got %s and %s", name, jsonOutput, envOutput, otherJSON, otherEnv)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package config
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strconv"
+)
+
+// Kinds of values that can be generated for an item.
+const (
+	GenerateKindPassword = "password"
+	GenerateKindUUID     = "uuid"
+	GenerateKindHex      = "hex"
+	GenerateKindPort     = "port"
+)
+
+const (
+	defaultPasswordLength = 24
+	defaultHexLength      = 32
+	defaultPasswordChars  = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789!#%+-.:=?@^_~"
+
+	// generated ports come from the dynamic/private range so they don't collide
+	// with well known services.
+	minGeneratedPort = 49152
+	maxGeneratedPort = 65535
+)
+
+// GenerateSpec describes how to fill in a missing value with a random one.
+type GenerateSpec struct {
+	Kind    string `json:"kind"`              // password, uuid, hex or port
+	Length  int    `json:"length,omitempty"`  // length of password or hex values
+	Charset string `json:"charset,omitempty"` // characters to use for passwords
+}
+
+// generateMissingValues fills in every item that has a generate spec but no value.
+// Items the user cleared on purpose stay empty.  It returns the keys of the items
+// that were generated.
+func generateMissingValues(configMap map[string]ItemConfig) ([]string, error) {
+	generated := []string{}
+	for _, key := range orderedKeys(configMap) {
+		item := configMap[key]
+		if item.Generate == nil || item.Default != "" || item.EmptyIsValid {
+			continue
+		}
+		value, err := generateValue(item.Generate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate value for '%s': %v", key, err)
+		}
+		item.Default = value
+		configMap[key] = item
+		generated = append(generated, key)
+	}
+	return generated, nil
+}
+
+// generateValue returns a cryptographically random value as described by spec.
+func generateValue(spec *GenerateSpec) (string, error) {
+	switch spec.Kind {
+	case GenerateKindPassword:
+		length := spec.Length
+		if length <= 0 {
+			length = defaultPasswordLength
+		}
+		charset := spec.Charset
+		if charset == "" {
+			charset = defaultPasswordChars
+		}
+		return randomString([]rune(charset), length)
+	case GenerateKindUUID:
+		return randomUUID()
+	case GenerateKindHex:
+		length := spec.Length
+		if length <= 0 {
+			length = defaultHexLength
+		}
+		buf := make([]byte, (length+1)/2)
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(buf)[:length], nil
+	case GenerateKindPort:
+		n, err := rand.Int(rand.Reader, big.NewInt(maxGeneratedPort-minGeneratedPort+1))
+		if err != nil {
+			return "", err
+		}
+		return strconv.Itoa(minGeneratedPort + int(n.Int64())), nil
+	}
+	return "", fmt.Errorf("unknown generate kind '%s'", spec.Kind)
+}
+
+// randomString picks length characters uniformly from charset.
+func randomString(charset []rune, length int) (string, error) {
+	max := big.NewInt(int64(len(charset)))
+	result := make([]rune, length)
+	for i := range result {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		result[i] = charset[n.Int64()]
+	}
+	return string(result), nil
+}
+
+// randomUUID returns a version 4 UUID.
+func randomUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+// RegenerateConfig generates fresh values for the given keys and saves them.
+// Every key must exist in the input file and have a generate spec.
+func RegenerateConfig(inputJSONFile string, keys []string) error {
+	s, err := newSession(inputJSONFile)
+	if err != nil {
+		return err
+	}
+	defer s.close()
+	configMap := s.configMap
+
+	for _, key := range keys {
+		item, exists := configMap[key]
+		if !exists {
+			return fmt.Errorf("setting '%s' not found in '%s'", key, inputJSONFile)
+		}
+		if item.Generate == nil {
+			return fmt.Errorf("setting '%s' does not have a generate spec", key)
+		}
+		value, err := generateValue(item.Generate)
+		if err != nil {
+			return fmt.Errorf("failed to generate value for '%s': %v", key, err)
+		}
+		item.Default = value
+		item.EmptyIsValid = false
+		configMap[key] = item
+	}
+
+	// fill in anything else that was never generated so the saved file is complete
+	if _, err := generateMissingValues(configMap); err != nil {
+		return err
+	}
+
+	return s.save()
+}
@@ -0,0 +1,179 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestGenerateValue(t *testing.T) {
+	// Password with explicit length and charset
+	value, err := generateValue(&GenerateSpec{Kind: GenerateKindPassword, Length: 40, Charset: "ab"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(value) != 40 || strings.Trim(value, "ab") != "" {
+		t.Errorf("Expected 40 characters from 'ab', got '%s'", value)
+	}
+
+	// Password with defaults
+	value, err = generateValue(&GenerateSpec{Kind: GenerateKindPassword})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(value) != defaultPasswordLength {
+		t.Errorf("Expected password of length %d, got '%s'", defaultPasswordLength, value)
+	}
+
+	// UUID
+	value, err = generateValue(&GenerateSpec{Kind: GenerateKindUUID})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	uuidPattern := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	if !uuidPattern.MatchString(value) {
+		t.Errorf("Expected a version 4 UUID, got '%s'", value)
+	}
+
+	// Hex with an odd length
+	value, err = generateValue(&GenerateSpec{Kind: GenerateKindHex, Length: 7})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !regexp.MustCompile(`^[0-9a-f]{7}$`).MatchString(value) {
+		t.Errorf("Expected 7 hex characters, got '%s'", value)
+	}
+
+	// Port
+	value, err = generateValue(&GenerateSpec{Kind: GenerateKindPort})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	port, err := strconv.Atoi(value)
+	if err != nil || port < minGeneratedPort || port > maxGeneratedPort {
+		t.Errorf("Expected a port between %d and %d, got '%s'", minGeneratedPort, maxGeneratedPort, value)
+	}
+
+	// Unknown kind
+	if _, err := generateValue(&GenerateSpec{Kind: "bogus"}); err == nil {
+		t.Error("Expected error for unknown kind, got nil")
+	}
+}
+
+func TestGenerateMissingValues(t *testing.T) {
+	configMap := map[string]ItemConfig{
+		"existing": {Default: "keep", Generate: &GenerateSpec{Kind: GenerateKindUUID}},
+		"missing":  {Generate: &GenerateSpec{Kind: GenerateKindUUID}},
+		"plain":    {},
+	}
+
+	generated, err := generateMissingValues(configMap)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(generated) != 1 || generated[0] != "missing" {
+		t.Errorf("Expected only 'missing' to be generated, got %v", generated)
+	}
+	if configMap["existing"].Default != "keep" {
+		t.Errorf("Expected existing value to be kept, got '%s'", configMap["existing"].Default)
+	}
+	if configMap["missing"].Default == "" {
+		t.Error("Expected a generated value for 'missing'")
+	}
+	if configMap["plain"].Default != "" {
+		t.Errorf("Expected 'plain' to stay empty, got '%s'", configMap["plain"].Default)
+	}
+}
+
+func TestCollectAndRegenerate(t *testing.T) {
+	dir, err := os.MkdirTemp("", "config_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	originalHome := os.Getenv("HOME")
+	os.Setenv("HOME", dir)
+	defer os.Setenv("HOME", originalHome)
+
+	inputJSON := `{
+		"dbPassword": {
+			"description": "Local database password",
+			"generate": {"kind": "password", "length": 16}
+		},
+		"instanceId": {
+			"description": "Instance id",
+			"generate": {"kind": "uuid"}
+		}
+	}`
+	inputJSONPath := filepath.Join(dir, "generated.json")
+	if err := os.WriteFile(inputJSONPath, []byte(inputJSON), 0644); err != nil {
+		t.Fatalf("Failed to write input JSON: %v", err)
+	}
+
+	// Silent collect must not fall into interactive mode for generated values.
+	if err := CollectConfig(inputJSONPath, true); err != nil {
+		t.Fatalf("Failed to run CollectConfig: %v", err)
+	}
+	first := readValues(t, inputJSONPath)
+	if len(first["dbPassword"]) != 16 || first["instanceId"] == "" {
+		t.Fatalf("Expected generated values, got %v", first)
+	}
+
+	// A second collect keeps the stored values.
+	if err := CollectConfig(inputJSONPath, true); err != nil {
+		t.Fatalf("Failed to run CollectConfig: %v", err)
+	}
+	second := readValues(t, inputJSONPath)
+	if second["dbPassword"] != first["dbPassword"] || second["instanceId"] != first["instanceId"] {
+		t.Errorf("Expected values to be kept, got %v then %v", first, second)
+	}
+
+	// Regenerate only touches the requested key.
+	if err := RegenerateConfig(inputJSONPath, []string{"dbPassword"}); err != nil {
+		t.Fatalf("Failed to regenerate: %v", err)
+	}
+	third := readValues(t, inputJSONPath)
+	if third["dbPassword"] == first["dbPassword"] {
+		t.Error("Expected dbPassword to be regenerated")
+	}
+	if third["instanceId"] != first["instanceId"] {
+		t.Error("Expected instanceId to be kept")
+	}
+
+	if err := RegenerateConfig(inputJSONPath, []string{"unknown"}); err == nil {
+		t.Error("Expected error for unknown key, got nil")
+	}
+}
+
+// readValues reads the saved JSON values for inputJSONPath.
+func readValues(t *testing.T, inputJSONPath string) map[string]string {
+	t.Helper()
+	jsonOutputFile, _, err := GetOutputFilePaths(inputJSONPath)
+	if err != nil {
+		t.Fatalf("Failed to get output file paths: %v", err)
+	}
+	content, err := os.ReadFile(jsonOutputFile)
+	if err != nil {
+		t.Fatalf("Failed to read JSON output file: %v", err)
+	}
+	raw := map[string]json.RawMessage{}
+	if err := json.Unmarshal(content, &raw); err != nil {
+		t.Fatalf("Failed to parse JSON output: %v", err)
+	}
+	// Entries that aren't strings, like emptyValuesKey, are kept as their JSON.
+	values := map[string]string{}
+	for key, value := range raw {
+		var s string
+		if json.Unmarshal(value, &s) == nil {
+			values[key] = s
+		} else {
+			values[key] = string(value)
+		}
+	}
+	return values
+}
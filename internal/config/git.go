@@ -0,0 +1,184 @@
+package config
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The functions in this file read Git repositories directly instead of running git,
+// so the tool works in minimal containers without git and doesn't fork a process
+// every time a shell starts.
+
+// errNotGitRepository is returned when a directory is not inside a Git repository.
+var errNotGitRepository = errors.New("not a git repository")
+
+// getGitRoot returns the top-level directory of the Git repository containing dir:
+// the closest directory, starting at dir, that has a .git directory or file.  A .git
+// file is what worktrees and submodules have.
+func getGitRoot(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		if info, err := os.Stat(filepath.Join(dir, ".git")); err == nil && (info.IsDir() || info.Mode().IsRegular()) {
+			return dir, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", errNotGitRepository
+		}
+		dir = parent
+	}
+}
+
+// getGitDir returns the Git directory of the repository whose top-level directory is
+// root.  For worktrees and submodules .git is a file pointing at it:
+//
+//	gitdir: ../.git/worktrees/feature
+func getGitDir(root string) (string, error) {
+	dotGit := filepath.Join(root, ".git")
+	info, err := os.Stat(dotGit)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return dotGit, nil
+	}
+
+	content, err := os.ReadFile(dotGit)
+	if err != nil {
+		return "", err
+	}
+	gitDir, found := strings.CutPrefix(strings.TrimSpace(string(content)), "gitdir:")
+	if !found {
+		return "", fmt.Errorf("'%s' does not point at a git directory", dotGit)
+	}
+	gitDir = strings.TrimSpace(gitDir)
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(root, gitDir)
+	}
+	return gitDir, nil
+}
+
+// getCommonGitDir returns the directory holding the configuration shared by all
+// worktrees of a repository.  A worktree's Git directory names it in its commondir
+// file; other Git directories are their own common directory.
+func getCommonGitDir(gitDir string) string {
+	content, err := os.ReadFile(filepath.Join(gitDir, "commondir"))
+	if err != nil {
+		return gitDir
+	}
+	commonDir := strings.TrimSpace(string(content))
+	if !filepath.IsAbs(commonDir) {
+		commonDir = filepath.Join(gitDir, commonDir)
+	}
+	return filepath.Clean(commonDir)
+}
+
+// getRemoteURL returns the URL of the origin remote of the repository whose top-level
+// directory is root.
+func getRemoteURL(root string) (string, error) {
+	gitDir, err := getGitDir(root)
+	if err != nil {
+		return "", err
+	}
+	configPath := filepath.Join(getCommonGitDir(gitDir), "config")
+	remote, err := readGitConfigValue(configPath, `remote "origin"`, "url")
+	if err != nil {
+		return "", err
+	}
+	if remote == "" {
+		return "", fmt.Errorf("repository '%s' has no origin remote", root)
+	}
+	return remote, nil
+}
+
+// readGitConfigValue returns the value of key in section of a Git config file, or ""
+// if it isn't set.  section is written as in the file, e.g. `remote "origin"`.  Only
+// the parts of the format that remotes use are supported: includes are not followed.
+func readGitConfigValue(configPath, section, key string) (string, error) {
+	file, err := os.Open(configPath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	wantSection := normalizeGitSection(section)
+	inSection := false
+	value := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if line[0] == '[' {
+			end := strings.Index(line, "]")
+			if end < 0 {
+				continue
+			}
+			inSection = normalizeGitSection(line[1:end]) == wantSection
+			line = strings.TrimSpace(line[end+1:])
+			if line == "" {
+				continue
+			}
+		}
+		if !inSection {
+			continue
+		}
+		name, rest, _ := strings.Cut(line, "=")
+		if strings.EqualFold(strings.TrimSpace(name), key) {
+			// later values override earlier ones, as in git
+			value = parseGitConfigValue(rest)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return value, nil
+}
+
+// normalizeGitSection lower-cases the section name, which is case-insensitive, but
+// keeps the subsection in quotes, which is not.
+func normalizeGitSection(section string) string {
+	name, subsection, found := strings.Cut(strings.TrimSpace(section), " ")
+	name = strings.ToLower(name)
+	if !found {
+		return name
+	}
+	return name + " " + strings.TrimSpace(subsection)
+}
+
+// parseGitConfigValue strips quotes, escapes and trailing comments from a value.
+func parseGitConfigValue(raw string) string {
+	var value strings.Builder
+	inQuotes := false
+	raw = strings.TrimSpace(raw)
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		switch {
+		case c == '"':
+			inQuotes = !inQuotes
+		case c == '\\' && i+1 < len(raw):
+			i++
+			switch raw[i] {
+			case 'n':
+				value.WriteByte('\n')
+			case 't':
+				value.WriteByte('\t')
+			default:
+				value.WriteByte(raw[i])
+			}
+		case (c == '#' || c == ';') && !inQuotes:
+			return strings.TrimSpace(value.String())
+		default:
+			value.WriteByte(c)
+		}
+	}
+	return strings.TrimSpace(value.String())
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetGitRootAndRemote(t *testing.T) {
+	dir, err := os.MkdirTemp("", "config_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// A repository, one of its worktrees and a submodule, laid out the way git does
+	// without needing git to be installed.
+	writeFiles(t, dir, map[string]string{
+		"main/.git/config": `[core]
+	bare = false
+[Remote "origin"]
+	url = "git@github.com:contoso/main.git" ; trailing comment
+	fetch = +refs/heads/*:refs/remotes/origin/*
+[remote "upstream"]
+	url = https://github.com/upstream/main.git
+`,
+		"main/.git/worktrees/feature/commondir": "../..\n",
+		"main/.git/modules/lib/config":          "[remote \"origin\"]\n\turl = https://github.com/contoso/lib\n",
+		"main/lib/.git":                         "gitdir: ../.git/modules/lib\n",
+		"main/lib/src/lib.go":                   "",
+		"feature/.git":                          "gitdir: " + filepath.Join(dir, "main/.git/worktrees/feature") + "\n",
+		"feature/api/settings.json":             "{}",
+		"nogit/settings.json":                   "{}",
+		"noremote/.git/config":                  "[core]\n\tbare = false\n",
+	})
+
+	tests := []struct {
+		start  string
+		root   string
+		remote string
+	}{
+		{"main", "main", "git@github.com:contoso/main.git"},
+		{"main/lib/src", "main/lib", "https://github.com/contoso/lib"},
+		{"feature/api", "feature", "git@github.com:contoso/main.git"},
+		{"noremote", "noremote", ""},
+	}
+	for _, test := range tests {
+		root, err := getGitRoot(filepath.Join(dir, test.start))
+		if err != nil {
+			t.Errorf("%s: expected no error, got %v", test.start, err)
+			continue
+		}
+		if root != filepath.Join(dir, test.root) {
+			t.Errorf("%s: expected root %s, got %s", test.start, filepath.Join(dir, test.root), root)
+		}
+		remote, err := getRemoteURL(root)
+		if test.remote == "" {
+			if err == nil {
+				t.Errorf("%s: expected error for missing remote, got %s", test.start, remote)
+			}
+			continue
+		}
+		if err != nil || remote != test.remote {
+			t.Errorf("%s: expected remote %s, got %s (%v)", test.start, test.remote, remote, err)
+		}
+	}
+
+	if root, err := getGitRoot(filepath.Join(dir, "nogit")); err == nil {
+		t.Errorf("Expected error outside a repository, got %s", root)
+	}
+}
+
+func TestParseGitConfigValue(t *testing.T) {
+	tests := map[string]string{
+		` plain `:                `plain`,
+		`"quoted # not comment"`: `quoted # not comment`,
+		`value # comment`:        `value`,
+		`a\"b`:                   `a"b`,
+	}
+	for raw, expected := range tests {
+		if value := parseGitConfigValue(raw); value != expected {
+			t.Errorf("%q: expected %q, got %q", raw, expected, value)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// HookStateEnvVar names the environment variable the shell hook keeps its state in:
+// which input file was loaded, the variables it exported and whether the files have
+// changed since.
+const HookStateEnvVar = "REPO_CONFIG_HOOK"
+
+// hookState is what the shell hook remembers between prompts.
+type hookState struct {
+	// InputFile is the input file whose values are loaded, or "" for none.
+	InputFile string `json:"input,omitempty"`
+	// Stamp changes whenever the input file, a file it includes or the values
+	// file changes, or the error loading them does.
+	Stamp string `json:"stamp,omitempty"`
+	// Names are the variables that were exported, to be removed on the next change.
+	Names []string `json:"names,omitempty"`
+}
+
+// HookShells lists the shells the prompt hook can be installed in.
+var HookShells = []string{ShellBash, ShellZsh, ShellFish}
+
+// FormatHook returns the script that installs the prompt hook in shell, which runs
+// executable to keep the environment in sync with the current directory.
+func FormatHook(shell, executable string) (string, error) {
+	switch shell {
+	case ShellBash:
+		return fmt.Sprintf(`_repo_config_hook() {
+  local previous_exit_status=$?
+  eval "$(%s hook-env bash)"
+  return $previous_exit_status
+}
+if [[ ";${PROMPT_COMMAND:-};" != *";_repo_config_hook;"* ]]; then
+  PROMPT_COMMAND="_repo_config_hook${PROMPT_COMMAND:+;$PROMPT_COMMAND}"
+fi
+`, quotePOSIX(executable)), nil
+	case ShellZsh:
+		return fmt.Sprintf(`_repo_config_hook() {
+  eval "$(%s hook-env zsh)"
+}
+typeset -ag precmd_functions chpwd_functions
+if (( ! ${precmd_functions[(I)_repo_config_hook]} )); then
+  precmd_functions=(_repo_config_hook $precmd_functions)
+fi
+if (( ! ${chpwd_functions[(I)_repo_config_hook]} )); then
+  chpwd_functions=(_repo_config_hook $chpwd_functions)
+fi
+`, quotePOSIX(executable)), nil
+	case ShellFish:
+		return fmt.Sprintf(`function __repo_config_hook --on-event fish_prompt --on-variable PWD
+    %s hook-env fish | source
+end
+`, quoteFish(executable)), nil
+	}
+	return "", checkHookShell(shell)
+}
+
+// checkHookShell returns an error for shells the prompt hook can't be installed in.
+func checkHookShell(shell string) error {
+	for _, s := range HookShells {
+		if shell == s {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown shell '%s': expected %s", shell, strings.Join(HookShells, ", "))
+}
+
+// HookEnv returns the statements the prompt hook evaluates in shell when the current
+// directory is dir, given the state the previous run left in HookStateEnvVar.  When
+// the directory belongs to another project, or the input or values file changed, the
+// previous project's variables are removed and the current one's exported.  When
+// nothing changed it returns nothing, having read no more than the input file and
+// the files it includes.  It never prompts:
+// values that haven't been collected are left out, with a note on stderr.
+func HookEnv(shell, dir, previousState string) (string, error) {
+	if err := checkHookShell(shell); err != nil {
+		return "", err
+	}
+	var previous hookState
+	if previousState != "" {
+		// A state that doesn't parse was left by something else; start over.
+		_ = json.Unmarshal([]byte(previousState), &previous)
+	}
+
+	state := findHookInput(dir)
+	if state.InputFile == previous.InputFile && state.Stamp == previous.Stamp {
+		return "", nil
+	}
+	if state.InputFile != previous.InputFile && state.InputFile != "" {
+		fmt.Fprintf(os.Stderr, "repo-config: loading %s\n", state.InputFile)
+	}
+	vars := loadHookVars(&state)
+
+	unsets, err := FormatUnsets(shell, previous.Names)
+	if err != nil {
+		return "", err
+	}
+	exports, err := FormatExports(shell, vars)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "repo-config: %v\n", err)
+		exports, state.Names = "", nil
+	}
+	stateJSON, err := json.Marshal(state)
+	if err != nil {
+		return "", err
+	}
+	stateExport, err := FormatExports(shell, []EnvVar{{HookStateEnvVar, string(stateJSON)}})
+	if err != nil {
+		return "", err
+	}
+	return unsets + exports + stateExport, nil
+}
+
+// findHookInput finds the input file for dir and stamps it with the modification
+// times of its files, without loading its values.  Errors are reported on stderr and
+// recorded in the stamp, so they are shown once rather than at every prompt.
+func findHookInput(dir string) hookState {
+	inputFile, err := FindInputFile(dir)
+	if errors.Is(err, errNoInputFile) {
+		return hookState{}
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "repo-config: %v\n", err)
+		return hookState{Stamp: err.Error()}
+	}
+	return hookState{InputFile: inputFile, Stamp: hookStamp(inputFile)}
+}
+
+// loadHookVars loads the variables defined by the values of state's input file and
+// records their names in state.  Settings without a value yet are left out.  It runs
+// at every prompt, so it neither waits for the project's lock nor writes anything.
+func loadHookVars(state *hookState) []EnvVar {
+	if state.InputFile == "" {
+		return nil
+	}
+	configMap, err := loadCollectedValues(state.InputFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "repo-config: %v\n", err)
+		return nil
+	}
+
+	vars := []EnvVar{}
+	missing := []string{}
+	for _, key := range activeKeys(configMap) {
+		item := configMap[key]
+		if item.isMissing() {
+			missing = append(missing, key)
+		} else if name := envVarName(key, item); name != "" {
+			vars = append(vars, EnvVar{Name: name, Value: item.Default})
+			state.Names = append(state.Names, name)
+		}
+	}
+	if len(missing) > 0 {
+		fmt.Fprintf(os.Stderr, "repo-config: %s has no values for %s: run repo-config collect\n", state.InputFile, strings.Join(missing, ", "))
+	}
+	return vars
+}
+
+// hookStamp returns a string that changes whenever one of the watchedFiles of
+// inputFile changes.
+func hookStamp(inputFile string) string {
+	stamp := []string{}
+	for _, file := range watchedFiles(inputFile) {
+		if info, err := os.Stat(file); err == nil {
+			stamp = append(stamp, fmt.Sprintf("%d:%d", info.ModTime().UnixNano(), info.Size()))
+		} else {
+			stamp = append(stamp, "-")
+		}
+	}
+	return strings.Join(stamp, ",")
+}
+
+// watchedFiles returns the files whose changes change the values of absInputFile: the
+// input file, the files it includes and its values file.  The input file is watched
+// even when it doesn't load, so fixing it reloads.
+func watchedFiles(absInputFile string) []string {
+	watched := []string{absInputFile}
+	if loader, err := newConfigLoader(absInputFile); err == nil {
+		if _, err := loader.load(); err == nil {
+			watched = loader.loadedFiles()
+		}
+	}
+	if outputs, err := locateOutputFiles(absInputFile); err == nil {
+		watched = append(watched, outputs.json)
+	}
+	return watched
+}
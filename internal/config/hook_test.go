@@ -0,0 +1,159 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newHookTestRepo lays out the repository of newSessionTestRepo with an input file the
+// hook finds on its own.
+func newHookTestRepo(t *testing.T) string {
+	t.Helper()
+	return newSessionTestRepoWithInput(t, "repo-config.yaml")
+}
+
+// hookStateFrom returns the state exported by the statements of HookEnv for bash.
+func hookStateFrom(t *testing.T, statements string) string {
+	t.Helper()
+	for _, line := range strings.Split(statements, "\n") {
+		if value, found := strings.CutPrefix(line, "export "+HookStateEnvVar+"="); found {
+			return strings.Trim(value, "'")
+		}
+	}
+	t.Fatalf("Expected the hook state in %q", statements)
+	return ""
+}
+
+func TestHookEnv(t *testing.T) {
+	inputFile := newHookTestRepo(t)
+	repo := filepath.Dir(filepath.Dir(inputFile))
+	api := filepath.Dir(inputFile)
+	writeFiles(t, repo, map[string]string{"web/index.html": ""})
+
+	// Entering the project exports its values.
+	statements, err := HookEnv(ShellBash, api, "")
+	if err != nil {
+		t.Fatalf("Failed to run hook: %v", err)
+	}
+	if !strings.Contains(statements, "export REGION='westus'\n") || !strings.Contains(statements, "export DB_PASSWORD=") {
+		t.Errorf("Expected the project's variables to be exported, got %q", statements)
+	}
+	state := hookStateFrom(t, statements)
+
+	// Nothing changed: nothing to do.
+	if statements, err := HookEnv(ShellBash, api, state); err != nil || statements != "" {
+		t.Errorf("Expected no statements when nothing changed, got %q (%v)", statements, err)
+	}
+
+	// New values are picked up.
+	jsonOutputFile, _, err := GetOutputFilePaths(inputFile)
+	if err != nil {
+		t.Fatalf("Failed to get output file paths: %v", err)
+	}
+	values := readValues(t, inputFile)
+	writeFiles(t, filepath.Dir(jsonOutputFile), map[string]string{
+		filepath.Base(jsonOutputFile): `{"region": "eastus", "dbPassword": "` + values["dbPassword"] + `", "cacheName": "cache"}`,
+	})
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(jsonOutputFile, future, future)
+	statements, err = HookEnv(ShellBash, api, state)
+	if err != nil || !strings.HasPrefix(statements, "unset REGION\nunset DB_PASSWORD\nexport REGION='eastus'\n") {
+		t.Errorf("Expected the new values to be exported, got %q (%v)", statements, err)
+	}
+	state = hookStateFrom(t, statements)
+
+	// Leaving the project removes them.
+	statements, err = HookEnv(ShellBash, filepath.Join(repo, "web"), state)
+	if err != nil || !strings.HasPrefix(statements, "unset REGION\nunset DB_PASSWORD\nexport "+HookStateEnvVar+"='{}'") {
+		t.Errorf("Expected the variables to be removed, got %q (%v)", statements, err)
+	}
+
+	if _, err := HookEnv("powershell", api, ""); err == nil {
+		t.Error("Expected an error for a shell without a hook")
+	}
+}
+
+func TestHookEnvWatchesIncludedFiles(t *testing.T) {
+	inputFile := newHookTestRepo(t)
+	api := filepath.Dir(inputFile)
+	writeFiles(t, api, map[string]string{
+		filepath.Base(inputFile): "$include: [shared.yaml]\nregion:\n  default: westus\n  tempEnvironmentVariableName: REGION\n",
+		"shared.yaml":            "owner:\n  default: me\n  tempEnvironmentVariableName: OWNER\n",
+	})
+
+	statements, err := HookEnv(ShellBash, api, "")
+	if err != nil || !strings.Contains(statements, "export OWNER='me'\n") {
+		t.Fatalf("Expected the included variables to be exported, got %q (%v)", statements, err)
+	}
+	state := hookStateFrom(t, statements)
+
+	// Editing the included file reloads.
+	writeFiles(t, api, map[string]string{"shared.yaml": "owner:\n  default: you\n  tempEnvironmentVariableName: OWNER\n"})
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(filepath.Join(api, "shared.yaml"), future, future)
+	statements, err = HookEnv(ShellBash, api, state)
+	if err != nil || !strings.Contains(statements, "export OWNER='you'\n") {
+		t.Errorf("Expected the edited include to be reloaded, got %q (%v)", statements, err)
+	}
+}
+
+func TestHookEnvSkipsMissingValues(t *testing.T) {
+	inputFile := newHookTestRepo(t)
+	jsonOutputFile, _, err := GetOutputFilePaths(inputFile)
+	if err != nil {
+		t.Fatalf("Failed to get output file paths: %v", err)
+	}
+	writeFiles(t, filepath.Dir(jsonOutputFile), map[string]string{filepath.Base(jsonOutputFile): `{"region": "westus"}`})
+
+	statements, err := HookEnv(ShellFish, filepath.Dir(inputFile), "")
+	if err != nil {
+		t.Fatalf("Failed to run hook: %v", err)
+	}
+	if !strings.HasPrefix(statements, "set -gx REGION 'westus'\nset -gx "+HookStateEnvVar) {
+		t.Errorf("Expected only the collected value to be exported, got %q", statements)
+	}
+}
+
+func TestHookEnvDoesNotWaitForCollect(t *testing.T) {
+	inputFile := newHookTestRepo(t)
+	jsonOutputFile, _, err := GetOutputFilePaths(inputFile)
+	if err != nil {
+		t.Fatalf("Failed to get output file paths: %v", err)
+	}
+
+	// Another terminal is collecting interactively.
+	lock, err := lockProject(filepath.Dir(jsonOutputFile), time.Second)
+	if err != nil {
+		t.Fatalf("Failed to lock project: %v", err)
+	}
+	defer lock.unlock()
+	settings := defaultSettings()
+	settings.LockTimeout = "1m"
+	UseSettings(settings)
+	defer UseSettings(defaultSettings())
+
+	start := time.Now()
+	statements, err := HookEnv(ShellBash, filepath.Dir(inputFile), "")
+	if err != nil || !strings.Contains(statements, "export REGION='westus'\n") {
+		t.Errorf("Expected the values to be exported, got %q (%v)", statements, err)
+	}
+	if time.Since(start) > 10*time.Second {
+		t.Errorf("Expected the hook not to wait for the lock, took %s", time.Since(start))
+	}
+}
+
+func TestHookEnvWritesNothing(t *testing.T) {
+	repo := newTestRepo(t)
+	t.Setenv("HOME", repo)
+	writeFiles(t, repo, map[string]string{"repo-config.json": `{"region": {"default": "westus"}}`})
+
+	if _, err := HookEnv(ShellBash, repo, ""); err != nil {
+		t.Fatalf("Failed to run hook: %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(repo, ".repo-config")); !os.IsNotExist(err) {
+		t.Errorf("Expected the hook not to create the store (%v)", err)
+	}
+}
@@ -0,0 +1,293 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Reserved top-level keys in an input file.  Every other key is a setting.
+//
+//	"$include": ["../shared/azure.json"]   settings merged in from other files
+//	"$defs": { "location": {...} }         item definitions that are only used by $ref
+//
+// A setting can start from another definition with "$ref": "#name" (a $defs entry or
+// setting in the same file) or "$ref": "path#name" (one in another file).  Fields set
+// on the setting itself override the referenced ones.  Paths are relative to the file
+// they appear in and must stay inside the repository.
+const (
+	includeKey = "$include"
+	defsKey    = "$defs"
+)
+
+// maxRefDepth bounds chains of $ref so that cycles are reported instead of looping.
+const maxRefDepth = 32
+
+// rawConfig is an input file before includes and references are resolved.
+type rawConfig struct {
+	includes []string
+	defs     map[string]json.RawMessage
+	keys     []string // settings in declaration order
+	items    map[string]json.RawMessage
+}
+
+// configLoader loads an input file together with the files it includes and refers to.
+type configLoader struct {
+	path    string                // the top level input file
+	root    string                // included and referenced files must be inside root
+	files   map[string]*rawConfig // parsed files by absolute path
+	loading map[string]bool       // files being included, to detect cycles
+	merged  map[string]bool       // files whose settings are merged in already
+}
+
+// newConfigLoader creates a loader for path.  Files it pulls in must be inside the
+// Git repository containing path, or inside its directory when it isn't in a repository.
+func newConfigLoader(path string) (*configLoader, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path of input JSON file: %v", err)
+	}
+	// Resolve symlinks so included paths are compared with the real location of the repository.
+	if resolved, err := filepath.EvalSymlinks(absPath); err == nil {
+		absPath = resolved
+	}
+	root, err := getGitRoot(filepath.Dir(absPath))
+	if err != nil {
+		root = filepath.Dir(absPath)
+	}
+	return &configLoader{
+		path:    absPath,
+		root:    root,
+		files:   map[string]*rawConfig{},
+		loading: map[string]bool{},
+		merged:  map[string]bool{},
+	}, nil
+}
+
+// load returns the merged settings of the input file.  Included settings come first,
+// in include order, followed by the file's own settings.
+func (l *configLoader) load() (map[string]ItemConfig, error) {
+	configMap := map[string]ItemConfig{}
+	origins := map[string]string{}
+	var keys []string
+	if err := l.merge(l.path, configMap, origins, &keys); err != nil {
+		return nil, err
+	}
+	for i, key := range keys {
+		item := configMap[key]
+		item.Order = i + 1
+		configMap[key] = item
+	}
+	return configMap, nil
+}
+
+// loadedFiles returns the input file and every file it includes or refers to, once
+// load has read them.
+func (l *configLoader) loadedFiles() []string {
+	files := make([]string, 0, len(l.files))
+	for path := range l.files {
+		files = append(files, path)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// merge adds the settings of path, and of everything it includes, to configMap.
+// origins records which file each setting came from for conflict errors.  A file
+// included by several others, like shared definitions, is merged once.
+func (l *configLoader) merge(path string, configMap map[string]ItemConfig, origins map[string]string, keys *[]string) error {
+	if l.loading[path] {
+		return fmt.Errorf("'%s' includes itself", l.display(path))
+	}
+	if l.merged[path] {
+		return nil
+	}
+	l.loading[path] = true
+	defer delete(l.loading, path)
+
+	cfg, err := l.parse(path)
+	if err != nil {
+		return err
+	}
+
+	for _, include := range cfg.includes {
+		includePath, err := l.resolvePath(path, include)
+		if err != nil {
+			return err
+		}
+		if err := l.merge(includePath, configMap, origins, keys); err != nil {
+			return err
+		}
+	}
+
+	for _, key := range cfg.keys {
+		if origin, exists := origins[key]; exists {
+			return fmt.Errorf("setting '%s' is defined in both '%s' and '%s'", key, l.display(origin), l.display(path))
+		}
+		item, err := l.resolveItem(path, cfg, cfg.items[key], 0)
+		if err != nil {
+			return fmt.Errorf("setting '%s' in '%s': %v", key, l.display(path), err)
+		}
+		configMap[key] = item
+		origins[key] = path
+		*keys = append(*keys, key)
+	}
+	l.merged[path] = true
+	return nil
+}
+
+// resolveItem decodes raw, starting from the definition it refers to if it has a $ref.
+func (l *configLoader) resolveItem(path string, cfg *rawConfig, raw json.RawMessage, depth int) (ItemConfig, error) {
+	var ref struct {
+		Ref string `json:"$ref"`
+	}
+	if err := json.Unmarshal(raw, &ref); err != nil {
+		return ItemConfig{}, err
+	}
+
+	var item ItemConfig
+	if ref.Ref != "" {
+		if depth >= maxRefDepth {
+			return ItemConfig{}, fmt.Errorf("$ref '%s' is nested too deeply (is there a cycle?)", ref.Ref)
+		}
+		refFile, refName, found := strings.Cut(ref.Ref, "#")
+		if !found || refName == "" {
+			return ItemConfig{}, fmt.Errorf("$ref '%s' must look like 'file#name' or '#name'", ref.Ref)
+		}
+
+		refPath, refCfg := path, cfg
+		if refFile != "" {
+			var err error
+			if refPath, err = l.resolvePath(path, refFile); err != nil {
+				return ItemConfig{}, err
+			}
+			if refCfg, err = l.parse(refPath); err != nil {
+				return ItemConfig{}, err
+			}
+		}
+
+		refRaw, exists := refCfg.defs[refName]
+		if !exists {
+			refRaw, exists = refCfg.items[refName]
+		}
+		if !exists {
+			return ItemConfig{}, fmt.Errorf("$ref '%s': '%s' is not defined in '%s'", ref.Ref, refName, l.display(refPath))
+		}
+		base, err := l.resolveItem(refPath, refCfg, refRaw, depth+1)
+		if err != nil {
+			return ItemConfig{}, err
+		}
+		item = base
+	}
+
+	// Fields on the item itself override the referenced definition.
+	if err := json.Unmarshal(raw, &item); err != nil {
+		return ItemConfig{}, err
+	}
+	if err := applySectionAlias(raw, &item); err != nil {
+		return ItemConfig{}, err
+	}
+	return item, nil
+}
+
+// resolvePath resolves a path found in from and checks that it stays inside the root.
+func (l *configLoader) resolvePath(from, path string) (string, error) {
+	if filepath.IsAbs(path) {
+		return "", fmt.Errorf("'%s' in '%s' must be a relative path", path, l.display(from))
+	}
+	resolved := filepath.Join(filepath.Dir(from), path)
+	rel, err := filepath.Rel(l.root, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("'%s' in '%s' is outside of '%s'", path, l.display(from), l.root)
+	}
+	return resolved, nil
+}
+
+// display returns path relative to the root for error messages.
+func (l *configLoader) display(path string) string {
+	if rel, err := filepath.Rel(l.root, path); err == nil {
+		return rel
+	}
+	return path
+}
+
+// parse reads and decodes path, caching the result.
+func (l *configLoader) parse(path string) (*rawConfig, error) {
+	if cfg, exists := l.files[path]; exists {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open JSON file: %v", err)
+	}
+	jsonData, err := toJSON(path, data)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := decodeRawConfig(json.NewDecoder(bytes.NewReader(jsonData)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse JSON file '%s': %v", l.display(path), err)
+	}
+	l.files[path] = cfg
+	return cfg, nil
+}
+
+// decodeRawConfig decodes the top-level object of an input file, keeping the
+// declaration order of the settings.
+func decodeRawConfig(decoder *json.Decoder) (*rawConfig, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return nil, fmt.Errorf("expected a JSON object of settings")
+	}
+
+	cfg := &rawConfig{
+		defs:  map[string]json.RawMessage{},
+		items: map[string]json.RawMessage{},
+	}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		key := token.(string) // object keys are always strings
+
+		switch key {
+		case includeKey:
+			if err := decoder.Decode(&cfg.includes); err != nil {
+				return nil, fmt.Errorf("%s must be a list of paths: %v", includeKey, err)
+			}
+			continue
+		case defsKey:
+			if err := decoder.Decode(&cfg.defs); err != nil {
+				return nil, fmt.Errorf("%s must be an object of item definitions: %v", defsKey, err)
+			}
+			continue
+		}
+		if strings.HasPrefix(key, "$") {
+			return nil, fmt.Errorf("unknown directive '%s'", key)
+		}
+		if _, exists := cfg.items[key]; exists {
+			return nil, fmt.Errorf("setting '%s' is defined more than once", key)
+		}
+
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("setting '%s': %v", key, err)
+		}
+		cfg.items[key] = raw
+		cfg.keys = append(cfg.keys, key)
+	}
+
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
@@ -0,0 +1,135 @@
+package config
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeFiles creates files (relative path to content) under dir.
+func writeFiles(t testing.TB, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+}
+
+// newTestRepo creates a temporary Git repository.
+func newTestRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir, err := os.MkdirTemp("", "config_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	if output, err := exec.Command("git", "init", "-q", dir).CombinedOutput(); err != nil {
+		t.Fatalf("Failed to init git repo: %v: %s", err, output)
+	}
+	return dir
+}
+
+func TestLoadConfigFileIncludesAndRefs(t *testing.T) {
+	repo := newTestRepo(t)
+	writeFiles(t, repo, map[string]string{
+		"shared/azure.json": `{
+			"$defs": {
+				"secret": {"description": "a secret", "required": true}
+			},
+			"azureLocation": {"description": "Azure location", "default": "uswest3"},
+			"subscription": {"description": "Azure subscription"}
+		}`,
+		"api/settings.json": `{
+			"$include": ["../shared/azure.json"],
+			"apiKey": {"$ref": "../shared/azure.json#secret", "tempEnvironmentVariableName": "API_KEY"},
+			"apiLocation": {"$ref": "../shared/azure.json#azureLocation", "description": "API location"},
+			"apiPassword": {"$ref": "#apiKey", "tempEnvironmentVariableName": "API_PASSWORD"}
+		}`,
+	})
+
+	configMap, err := loadConfigFile(filepath.Join(repo, "api", "settings.json"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := []string{"azureLocation", "subscription", "apiKey", "apiLocation", "apiPassword"}
+	if keys := orderedKeys(configMap); !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Expected keys %v, got %v", expected, keys)
+	}
+	if item := configMap["apiKey"]; item.Description != "a secret" || item.TempEnvironmentVariableName != "API_KEY" {
+		t.Errorf("Unexpected apiKey %+v", item)
+	}
+	if item := configMap["apiLocation"]; item.Description != "API location" || item.Default != "uswest3" {
+		t.Errorf("Unexpected apiLocation %+v", item)
+	}
+	if item := configMap["apiPassword"]; item.Description != "a secret" || item.TempEnvironmentVariableName != "API_PASSWORD" {
+		t.Errorf("Unexpected apiPassword %+v", item)
+	}
+}
+
+func TestLoadConfigFileIncludeErrors(t *testing.T) {
+	repo := newTestRepo(t)
+	writeFiles(t, repo, map[string]string{
+		"shared/a.json":     `{"location": {"description": "a"}}`,
+		"shared/b.json":     `{"location": {"description": "b"}}`,
+		"conflict.json":     `{"$include": ["shared/a.json", "shared/b.json"]}`,
+		"override.json":     `{"$include": ["shared/a.json"], "location": {"description": "mine"}}`,
+		"cycle.json":        `{"$include": ["cycle2.json"]}`,
+		"cycle2.json":       `{"$include": ["cycle.json"]}`,
+		"refcycle.json":     `{"a": {"$ref": "#b"}, "b": {"$ref": "#a"}}`,
+		"missing-ref.json":  `{"a": {"$ref": "shared/a.json#nope"}}`,
+		"outside.json":      `{"$include": ["../outside.json"]}`,
+		"absolute.json":     `{"$include": ["/etc/passwd"]}`,
+		"unknown-directive": `{"$extends": "shared/a.json"}`,
+	})
+
+	tests := map[string]string{
+		"conflict.json":     "defined in both 'shared/a.json' and 'shared/b.json'",
+		"override.json":     "defined in both 'shared/a.json' and 'override.json'",
+		"cycle.json":        "includes itself",
+		"refcycle.json":     "nested too deeply",
+		"missing-ref.json":  "'nope' is not defined",
+		"outside.json":      "is outside of",
+		"absolute.json":     "must be a relative path",
+		"unknown-directive": "unknown directive '$extends'",
+	}
+	for name, expected := range tests {
+		_, err := loadConfigFile(filepath.Join(repo, name))
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("%s: expected error containing %q, got %v", name, expected, err)
+		}
+	}
+}
+
+func TestLoadConfigFileDiamondInclude(t *testing.T) {
+	repo := newTestRepo(t)
+	writeFiles(t, repo, map[string]string{
+		"d/base.json":  `{"shared": {"description": "shared", "default": "x"}}`,
+		"d/b.json":     `{"$include": ["base.json"], "fromB": {"$ref": "base.json#shared"}}`,
+		"d/c.json":     `{"$include": ["base.json"], "fromC": {"description": "c"}}`,
+		"diamond.json": `{"$include": ["d/b.json", "d/c.json"], "own": {"description": "own"}}`,
+	})
+
+	configMap, err := loadConfigFile(filepath.Join(repo, "diamond.json"))
+	if err != nil {
+		t.Fatalf("Expected a file included twice to be merged once, got %v", err)
+	}
+	expected := []string{"shared", "fromB", "fromC", "own"}
+	if keys := orderedKeys(configMap); !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Expected keys %v, got %v", expected, keys)
+	}
+	if configMap["fromB"].Default != "x" {
+		t.Errorf("Expected fromB to start from shared, got %+v", configMap["fromB"])
+	}
+}
@@ -0,0 +1,219 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+)
+
+// SourceKeyVault is the scheme of values kept as Azure Key Vault secrets:
+// "keyvault://<vault>/<secret>[/<version>]", e.g. "keyvault://contoso-dev/db-password".
+// Without a version the current one is read.
+const SourceKeyVault = "keyvault"
+
+// KeyVaultEndpointEnvVar overrides the URL secrets are read from, for other Azure
+// clouds or a local stand-in.  {vault} in it is replaced by the name of the vault.
+const KeyVaultEndpointEnvVar = "REPO_CONFIG_KEYVAULT_ENDPOINT"
+
+// Environment variables of the service principal or workload identity to get tokens
+// for, the same ones the Azure SDKs read.  Without them the token of the az CLI's
+// signed in account is used.
+const (
+	azureTenantIDEnvVar           = "AZURE_TENANT_ID"
+	azureClientIDEnvVar           = "AZURE_CLIENT_ID"
+	azureClientSecretEnvVar       = "AZURE_CLIENT_SECRET"
+	azureFederatedTokenFileEnvVar = "AZURE_FEDERATED_TOKEN_FILE"
+	azureAuthorityHostEnvVar      = "AZURE_AUTHORITY_HOST"
+)
+
+const (
+	defaultKeyVaultEndpoint   = "https://{vault}.vault.azure.net"
+	defaultAzureAuthorityHost = "https://login.microsoftonline.com"
+	keyVaultResource          = "https://vault.azure.net"
+	keyVaultAPIVersion        = "7.4"
+)
+
+// keyVaultProvider resolves values from Key Vault.  The token and each secret are
+// fetched once per run.
+type keyVaultProvider struct {
+	client  *http.Client
+	token   string
+	secrets map[string]string
+}
+
+// newKeyVaultProvider returns a Key Vault provider with an empty cache.
+func newKeyVaultProvider() *keyVaultProvider {
+	return &keyVaultProvider{
+		client:  &http.Client{Timeout: httpProviderTimeout},
+		secrets: make(map[string]string),
+	}
+}
+
+func (p *keyVaultProvider) Resolve(ctx context.Context, key string, item ItemConfig) (string, error) {
+	_, ref := parseSource(item.Source)
+	parts := strings.Split(strings.TrimPrefix(ref, "//"), "/")
+	if !strings.HasPrefix(ref, "//") || len(parts) < 2 || len(parts) > 3 || slices.Contains(parts, "") {
+		return "", &providerError{code: "invalid_source", message: fmt.Sprintf("expected 'keyvault://<vault>/<secret>[/<version>]', e.g. 'keyvault://contoso-dev/%s'", key)}
+	}
+	vault, secretPath := parts[0], strings.Join(parts[1:], "/")
+
+	cacheKey := vault + "/" + secretPath
+	if value, exists := p.secrets[cacheKey]; exists {
+		return value, nil
+	}
+	token, err := p.accessToken(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	endpoint := os.Getenv(KeyVaultEndpointEnvVar)
+	if endpoint == "" {
+		endpoint = defaultKeyVaultEndpoint
+	}
+	endpoint = strings.TrimRight(strings.ReplaceAll(endpoint, "{vault}", url.PathEscape(vault)), "/")
+	secretURL := endpoint + "/secrets/" + secretPath + "?api-version=" + keyVaultAPIVersion
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, secretURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("invalid Key Vault URL '%s': %v", secretURL, err)
+	}
+	request.Header.Set("Authorization", "Bearer "+token)
+
+	response, err := p.client.Do(request)
+	if err != nil {
+		return "", fmt.Errorf("failed to reach Key Vault '%s': %v", vault, err)
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read Key Vault secret '%s': %v", cacheKey, err)
+	}
+
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return "", &providerError{code: "permission_denied", message: fmt.Sprintf("permission denied reading Key Vault secret '%s'%s: check that the signed in identity may get secrets of the vault", cacheKey, azureError(body))}
+	case http.StatusNotFound:
+		return "", &providerError{code: "not_found", message: fmt.Sprintf("Key Vault secret '%s' not found%s", cacheKey, azureError(body))}
+	default:
+		return "", fmt.Errorf("failed to read Key Vault secret '%s': %s%s", cacheKey, response.Status, azureError(body))
+	}
+
+	var secret struct {
+		Value *string `json:"value"`
+	}
+	if err := json.Unmarshal(body, &secret); err != nil || secret.Value == nil {
+		return "", fmt.Errorf("failed to parse Key Vault secret '%s'", cacheKey)
+	}
+	p.secrets[cacheKey] = *secret.Value
+	return *secret.Value, nil
+}
+
+// accessToken returns a token for Key Vault, acquiring it the first time: from the
+// service principal or workload identity in the environment if there is one, and from
+// the az CLI otherwise.
+func (p *keyVaultProvider) accessToken(ctx context.Context) (string, error) {
+	if p.token != "" {
+		return p.token, nil
+	}
+	var err error
+	if os.Getenv(azureClientIDEnvVar) != "" && os.Getenv(azureTenantIDEnvVar) != "" &&
+		(os.Getenv(azureClientSecretEnvVar) != "" || os.Getenv(azureFederatedTokenFileEnvVar) != "") {
+		p.token, err = p.environmentToken(ctx)
+	} else {
+		p.token, err = azCLIToken(ctx)
+	}
+	return p.token, err
+}
+
+// environmentToken gets a token with the client credentials flow, for the client
+// secret or federated token in the environment.
+func (p *keyVaultProvider) environmentToken(ctx context.Context) (string, error) {
+	tenant := os.Getenv(azureTenantIDEnvVar)
+	form := url.Values{
+		"grant_type": {"client_credentials"},
+		"client_id":  {os.Getenv(azureClientIDEnvVar)},
+		"scope":      {keyVaultResource + "/.default"},
+	}
+	if secret := os.Getenv(azureClientSecretEnvVar); secret != "" {
+		form.Set("client_secret", secret)
+	} else {
+		assertion, err := os.ReadFile(os.Getenv(azureFederatedTokenFileEnvVar))
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %v", azureFederatedTokenFileEnvVar, err)
+		}
+		form.Set("client_assertion_type", "urn:ietf:params:oauth:client-assertion-type:jwt-bearer")
+		form.Set("client_assertion", strings.TrimSpace(string(assertion)))
+	}
+
+	authority := os.Getenv(azureAuthorityHostEnvVar)
+	if authority == "" {
+		authority = defaultAzureAuthorityHost
+	}
+	tokenURL := strings.TrimRight(authority, "/") + "/" + url.PathEscape(tenant) + "/oauth2/v2.0/token"
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("invalid %s '%s': %v", azureAuthorityHostEnvVar, authority, err)
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	response, err := p.client.Do(request)
+	if err != nil {
+		return "", fmt.Errorf("failed to get a Key Vault token: %v", err)
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to get a Key Vault token: %v", err)
+	}
+	var token struct {
+		AccessToken      string `json:"access_token"`
+		ErrorDescription string `json:"error_description"`
+	}
+	json.Unmarshal(body, &token)
+	if response.StatusCode != http.StatusOK || token.AccessToken == "" {
+		message := token.ErrorDescription
+		if message == "" {
+			message = response.Status
+		}
+		return "", fmt.Errorf("failed to get a Key Vault token for client '%s' in tenant '%s': %s", os.Getenv(azureClientIDEnvVar), tenant, message)
+	}
+	return token.AccessToken, nil
+}
+
+// azCLIToken gets a token for the account the az CLI is signed in with.
+func azCLIToken(ctx context.Context) (string, error) {
+	cmd := exec.CommandContext(ctx, "az", "account", "get-access-token", "--resource", keyVaultResource, "--query", "accessToken", "--output", "tsv")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if errors.Is(err, exec.ErrNotFound) {
+		return "", fmt.Errorf("no Azure credentials: set %s, %s and %s, or install the az CLI and run az login", azureTenantIDEnvVar, azureClientIDEnvVar, azureClientSecretEnvVar)
+	} else if err != nil {
+		return "", fmt.Errorf("failed to get a Key Vault token from the az CLI (run az login): %s", strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// azureError returns the message of an Azure error response, formatted to follow a
+// message, or "" if it has none.
+func azureError(body []byte) string {
+	var response struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &response) != nil || response.Error.Message == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (%s: %s)", response.Error.Code, response.Error.Message)
+}
@@ -0,0 +1,123 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// newKeyVaultTestServer starts a stand-in for Key Vault and its token endpoint.  The
+// vault "contoso" holds the secret db-password, in two versions, for the token
+// "token"; any other vault refuses it.  requests counts the requests by path.
+func newKeyVaultTestServer(t *testing.T, requests map[string]int) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests[r.URL.Path]++
+		switch {
+		case r.URL.Path == "/tenant/oauth2/v2.0/token":
+			r.ParseForm()
+			if r.Form.Get("client_secret") != "secret" || r.Form.Get("scope") != "https://vault.azure.net/.default" {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"error": "invalid_client", "error_description": "AADSTS7000215: Invalid client secret provided."}`))
+				return
+			}
+			w.Write([]byte(`{"access_token": "token", "token_type": "Bearer"}`))
+		case r.Header.Get("Authorization") != "Bearer token" || !strings.HasPrefix(r.URL.Path, "/contoso/"):
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error": {"code": "Forbidden", "message": "The user does not have secrets get permission."}}`))
+		case r.URL.Query().Get("api-version") == "":
+			w.WriteHeader(http.StatusBadRequest)
+		case r.URL.Path == "/contoso/secrets/db-password":
+			w.Write([]byte(`{"value": "current", "id": "https://contoso.vault.azure.net/secrets/db-password/2"}`))
+		case r.URL.Path == "/contoso/secrets/db-password/1":
+			w.Write([]byte(`{"value": "previous"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error": {"code": "SecretNotFound", "message": "A secret with (name/id) other was not found in this key vault."}}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv(KeyVaultEndpointEnvVar, server.URL+"/{vault}")
+	t.Setenv(azureAuthorityHostEnvVar, server.URL)
+	t.Setenv(azureTenantIDEnvVar, "tenant")
+	t.Setenv(azureClientIDEnvVar, "client")
+	t.Setenv(azureClientSecretEnvVar, "secret")
+	t.Setenv(azureFederatedTokenFileEnvVar, "")
+}
+
+func TestKeyVaultProvider(t *testing.T) {
+	requests := map[string]int{}
+	newKeyVaultTestServer(t, requests)
+	configMap := map[string]ItemConfig{
+		"dbPassword":         {Source: "keyvault://contoso/db-password", Order: 1},
+		"previousDbPassword": {Source: "keyvault://contoso/db-password/1", Order: 2},
+		"samePassword":       {Source: "keyvault://contoso/db-password", Order: 3},
+	}
+	if _, err := resolveValues(context.Background(), configMap, newProviders("repo-config.json", scriptRunner{}), scriptRunner{}); err != nil {
+		t.Fatalf("Failed to resolve sources: %v", err)
+	}
+	for key, expected := range map[string]string{"dbPassword": "current", "previousDbPassword": "previous", "samePassword": "current"} {
+		if configMap[key].Default != expected {
+			t.Errorf("Expected %s to be %q, got %q", key, expected, configMap[key].Default)
+		}
+	}
+	if requests["/tenant/oauth2/v2.0/token"] != 1 || requests["/contoso/secrets/db-password"] != 1 {
+		t.Errorf("Expected the token and each secret to be fetched once, got %v", requests)
+	}
+}
+
+func TestKeyVaultProviderErrors(t *testing.T) {
+	newKeyVaultTestServer(t, map[string]int{})
+	for source, expected := range map[string][2]string{
+		"keyvault://contoso/other":     {"Key Vault secret 'contoso/other' not found (SecretNotFound: ", "not_found"},
+		"keyvault://fabrikam/secret":   {"permission denied reading Key Vault secret 'fabrikam/secret' (Forbidden: The user does not have secrets get permission.)", "permission_denied"},
+		"keyvault://contoso":           {"expected 'keyvault://<vault>/<secret>[/<version>]'", "invalid_source"},
+		"keyvault:contoso/db-password": {"expected 'keyvault://<vault>/<secret>[/<version>]'", "invalid_source"},
+	} {
+		_, err := resolveValues(context.Background(), map[string]ItemConfig{"key": {Source: source}}, newProviders("repo-config.json", scriptRunner{}), scriptRunner{})
+		var failed SourceErrors
+		if !errors.As(err, &failed) || !strings.Contains(err.Error(), expected[0]) || failed[0].Code != expected[1] {
+			t.Errorf("Expected a %s error containing %q for %s, got %v", expected[1], expected[0], source, err)
+		}
+	}
+
+	t.Setenv(azureClientSecretEnvVar, "wrong")
+	_, err := resolveValues(context.Background(), map[string]ItemConfig{"key": {Source: "keyvault://contoso/db-password"}}, newProviders("repo-config.json", scriptRunner{}), scriptRunner{})
+	if err == nil || !strings.Contains(err.Error(), "for client 'client' in tenant 'tenant': AADSTS7000215") {
+		t.Errorf("Expected a token error, got %v", err)
+	}
+}
+
+func TestKeyVaultProviderAzCLI(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the stand-in for az is a shell script")
+	}
+	newKeyVaultTestServer(t, map[string]int{})
+	t.Setenv(azureClientSecretEnvVar, "")
+	bin := t.TempDir()
+	writeFiles(t, bin, map[string]string{"az": "#!/bin/sh\necho token\n"})
+	if err := os.Chmod(filepath.Join(bin, "az"), 0755); err != nil {
+		t.Fatalf("Failed to make az executable: %v", err)
+	}
+	t.Setenv("PATH", bin)
+
+	configMap := map[string]ItemConfig{"dbPassword": {Source: "keyvault://contoso/db-password"}}
+	if _, err := resolveValues(context.Background(), configMap, newProviders("repo-config.json", scriptRunner{}), scriptRunner{}); err != nil {
+		t.Fatalf("Failed to resolve sources: %v", err)
+	}
+	if configMap["dbPassword"].Default != "current" {
+		t.Errorf("Expected the secret read with the az CLI's token, got %q", configMap["dbPassword"].Default)
+	}
+
+	t.Setenv("PATH", t.TempDir())
+	_, err := resolveValues(context.Background(), configMap, newProviders("repo-config.json", scriptRunner{}), scriptRunner{})
+	if err == nil || !strings.Contains(err.Error(), "no Azure credentials") {
+		t.Errorf("Expected an error without credentials, got %v", err)
+	}
+}
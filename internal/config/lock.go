@@ -0,0 +1,62 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// lockFileName is the file in a project's directory that processes lock while they
+// read and write the project's values.  VS Code starts several terminals at once, and
+// each one collects the same values.
+const lockFileName = ".lock"
+
+// lockPollInterval is how often a process waiting for a project's lock retries.
+const lockPollInterval = 50 * time.Millisecond
+
+// projectLock is an advisory lock on the values of a project.  The lock is released
+// when the process exits, even if it doesn't unlock.
+type projectLock struct {
+	file *os.File
+	// waited reports whether another process held the lock first.
+	waited bool
+}
+
+// lockProject locks the project whose values are stored in dir, waiting up to timeout
+// for another process to release it.
+func lockProject(dir string, timeout time.Duration) (*projectLock, error) {
+	path := filepath.Join(dir, lockFileName)
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %v", err)
+	}
+
+	lock := &projectLock{file: file}
+	deadline := time.Now().Add(timeout)
+	for {
+		locked, err := tryLockFile(file)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to lock '%s': %v", path, err)
+		}
+		if locked {
+			return lock, nil
+		}
+		if !lock.waited {
+			fmt.Fprintf(os.Stderr, "Waiting for another repo-config process to finish with %s\n", dir)
+			lock.waited = true
+		}
+		if time.Now().After(deadline) {
+			file.Close()
+			return nil, fmt.Errorf("timed out after %s waiting for another repo-config process to finish with '%s'", timeout, dir)
+		}
+		time.Sleep(lockPollInterval)
+	}
+}
+
+// unlock releases the lock.
+func (l *projectLock) unlock() {
+	// Closing the file releases the lock.
+	l.file.Close()
+}
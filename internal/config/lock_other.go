@@ -0,0 +1,14 @@
+//go:build !unix
+
+package config
+
+import "os"
+
+// locksSupported reports whether tryLockFile really locks.
+const locksSupported = false
+
+// tryLockFile always succeeds: advisory locks are only taken on Unix, so elsewhere
+// concurrent collects are not serialized.
+func tryLockFile(file *os.File) (bool, error) {
+	return true, nil
+}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCollectWaitsForAnotherProcess(t *testing.T) {
+	if !locksSupported {
+		t.Skip("advisory locks are only taken on Unix")
+	}
+	inputFile := newSessionTestRepo(t)
+	jsonOutputFile, _, err := GetOutputFilePaths(inputFile)
+	if err != nil {
+		t.Fatalf("Failed to get output file paths: %v", err)
+	}
+
+	// Another process is collecting the values, and saves them before it finishes.
+	lock, err := lockProject(filepath.Dir(jsonOutputFile), time.Second)
+	if err != nil {
+		t.Fatalf("Failed to lock project: %v", err)
+	}
+	if lock.waited {
+		t.Error("Expected the lock to be free")
+	}
+	writeFiles(t, filepath.Dir(jsonOutputFile), map[string]string{
+		filepath.Base(jsonOutputFile): `{"region": "eastus", "dbPassword": "secret"}`,
+	})
+
+	done := make(chan *session)
+	go func() {
+		s, err := newSession(inputFile)
+		if err != nil {
+			t.Errorf("Failed to create session: %v", err)
+		}
+		done <- s
+	}()
+	select {
+	case <-done:
+		t.Fatal("Expected the session to wait for the lock")
+	case <-time.After(2 * lockPollInterval):
+	}
+	lock.unlock()
+
+	s := <-done
+	if s == nil {
+		return
+	}
+	defer s.close()
+	if !s.lock.waited {
+		t.Error("Expected the session to know it waited")
+	}
+	// The values saved by the other process are used without prompting.
+	if err := s.collect(true, strings.NewReader("")); err != nil {
+		t.Errorf("Expected the other process's values to be reused, got %v", err)
+	}
+	if values := readValues(t, inputFile); values["region"] != "eastus" {
+		t.Errorf("Expected the other process's region, got %v", values)
+	}
+}
+
+func TestCollectDoesNotPromptAfterWaiting(t *testing.T) {
+	inputFile := newSessionTestRepo(t)
+	jsonOutputFile, _, err := GetOutputFilePaths(inputFile)
+	if err != nil {
+		t.Fatalf("Failed to get output file paths: %v", err)
+	}
+	writeFiles(t, filepath.Dir(jsonOutputFile), map[string]string{filepath.Base(jsonOutputFile): `{"region": ""}`})
+
+	s, err := newSession(inputFile)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	defer s.close()
+	s.lock.waited = true
+	if err := s.collect(true, strings.NewReader("")); err == nil || !strings.Contains(err.Error(), "run collect again") {
+		t.Errorf("Expected an error instead of a prompt, got %v", err)
+	}
+}
+
+func TestLockProjectTimeout(t *testing.T) {
+	if !locksSupported {
+		t.Skip("advisory locks are only taken on Unix")
+	}
+	dir := t.TempDir()
+	lock, err := lockProject(dir, 0)
+	if err != nil {
+		t.Fatalf("Failed to lock project: %v", err)
+	}
+	defer lock.unlock()
+
+	if _, err := lockProject(dir, lockPollInterval); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("Expected a timeout, got %v", err)
+	}
+}
@@ -0,0 +1,22 @@
+//go:build unix
+
+package config
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// locksSupported reports whether tryLockFile really locks.
+const locksSupported = true
+
+// tryLockFile takes an exclusive advisory lock on file without waiting.  It reports
+// false if another process holds the lock.
+func tryLockFile(file *os.File) (bool, error) {
+	err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,222 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// manifestNames are the file names of the repository manifest, in order of preference.
+// The manifest lives at the repository root and lists the input files of the repo:
+//
+//	inputs:
+//	  - api/settings.json
+//	  - services/*/settings.yaml
+var manifestNames = []string{".repo-config.yaml", ".repo-config.yml", ".repo-config.json"}
+
+// inputFileSuffixes are the names that are discovered as input files when a repository
+// has no manifest.
+var inputFileSuffixes = []string{".repo-config.json", ".repo-config.jsonc", ".repo-config.yaml", ".repo-config.yml"}
+
+// defaultInputNames are the input file names FindInputFile looks for, besides files
+// named *.repo-config.json and friends.
+var defaultInputNames = []string{"repo-config.json", "repo-config.jsonc", "repo-config.yaml", "repo-config.yml"}
+
+// skippedDirs are never searched when discovering input files.
+var skippedDirs = map[string]bool{".git": true, "node_modules": true, "vendor": true}
+
+// manifest is the repository manifest.
+type manifest struct {
+	Inputs []string `json:"inputs"`
+}
+
+// DiscoverInputFiles returns the input files of the repository containing startDir:
+// the ones listed in the manifest if there is one, otherwise every file named
+// repo-config.json or *.repo-config.json (or .jsonc, .yaml, .yml) in the repository.
+func DiscoverInputFiles(startDir string) ([]string, error) {
+	absDir, err := filepath.Abs(startDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path of '%s': %v", startDir, err)
+	}
+	root, err := getGitRoot(absDir)
+	if err != nil {
+		root = absDir
+	}
+
+	for _, name := range manifestNames {
+		manifestPath := filepath.Join(root, name)
+		if _, err := os.Stat(manifestPath); err == nil {
+			return loadManifest(root, manifestPath)
+		}
+	}
+	return findInputFiles(root)
+}
+
+// errNoInputFile is returned by FindInputFile when there is no input file to find.
+var errNoInputFile = errors.New("no input file found")
+
+// FindInputFile looks for a conventionally named input file in startDir and each of
+// its parents up to the root of the Git repository.  The closest one wins; two
+// candidates in the same directory are an error.
+func FindInputFile(startDir string) (string, error) {
+	dir, err := filepath.Abs(startDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to get absolute path of '%s': %v", startDir, err)
+	}
+	root, err := getGitRoot(dir)
+	if err != nil {
+		// Outside of a repository only the start directory is searched.
+		root = dir
+	}
+
+	for {
+		candidates, err := inputFilesIn(dir)
+		if err != nil {
+			return "", err
+		}
+		switch len(candidates) {
+		case 0:
+		case 1:
+			return candidates[0], nil
+		default:
+			return "", fmt.Errorf("found several input files in '%s' (%s): use --json to pick one", dir, strings.Join(candidates, ", "))
+		}
+
+		parent := filepath.Dir(dir)
+		if dir == root || parent == dir || !strings.HasPrefix(dir, root) {
+			break
+		}
+		dir = parent
+	}
+	return "", fmt.Errorf("%w between '%s' and '%s': use --json or add a repo-config.json", errNoInputFile, startDir, root)
+}
+
+// inputFilesIn returns the conventionally named input files in dir.
+func inputFilesIn(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory '%s': %v", dir, err)
+	}
+	candidates := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() || !isInputFileName(entry.Name()) {
+			continue
+		}
+		candidates = append(candidates, filepath.Join(dir, entry.Name()))
+	}
+	return candidates, nil
+}
+
+// isInputFileName reports whether name is a conventional input file name.
+func isInputFileName(name string) bool {
+	for _, manifestName := range manifestNames {
+		if name == manifestName {
+			return false
+		}
+	}
+	for _, defaultName := range defaultInputNames {
+		if name == defaultName {
+			return true
+		}
+	}
+	for _, suffix := range inputFileSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadManifest reads the manifest and expands the globs in it.  Entries are relative to
+// the repository root and may only match files inside it.
+func loadManifest(root, manifestPath string) ([]string, error) {
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %v", err)
+	}
+	jsonData, err := toJSON(manifestPath, data)
+	if err != nil {
+		return nil, err
+	}
+	var m manifest
+	if err := json.Unmarshal(jsonData, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest '%s': %v", manifestPath, err)
+	}
+
+	files := []string{}
+	seen := map[string]bool{}
+	for _, input := range m.Inputs {
+		if filepath.IsAbs(input) {
+			return nil, fmt.Errorf("manifest entry '%s' must be relative to the repository root", input)
+		}
+		matches, err := filepath.Glob(filepath.Join(root, input))
+		if err != nil {
+			return nil, fmt.Errorf("invalid manifest entry '%s': %v", input, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("manifest entry '%s' does not match any file", input)
+		}
+		for _, match := range matches {
+			if rel, err := filepath.Rel(root, match); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				return nil, fmt.Errorf("manifest entry '%s' matches '%s', which is outside the repository root", input, match)
+			}
+			if !seen[match] {
+				seen[match] = true
+				files = append(files, match)
+			}
+		}
+	}
+	return files, nil
+}
+
+// findInputFiles walks root looking for conventionally named input files.
+func findInputFiles(root string) ([]string, error) {
+	files := []string{}
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if path != root && skippedDirs[entry.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if isInputFileName(entry.Name()) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search for input files: %v", err)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// CollectAllConfigs runs CollectConfig for every input file of the repository
+// containing startDir and returns the status of each one.  It only returns an error
+// when the input files can't be found; failures of individual files are reported in
+// their status.
+func CollectAllConfigs(startDir string, silent bool) ([]StatusOutput, error) {
+	inputFiles, err := DiscoverInputFiles(startDir)
+	if err != nil {
+		return nil, err
+	}
+	if len(inputFiles) == 0 {
+		return nil, fmt.Errorf("no input files found: add a .repo-config.yaml manifest or name files *.repo-config.json")
+	}
+
+	results := []StatusOutput{}
+	for _, inputFile := range inputFiles {
+		result := CollectConfigStatus(inputFile, silent)
+		result.InputFile = inputFile
+		results = append(results, result)
+	}
+	return results, nil
+}
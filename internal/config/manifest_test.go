@@ -0,0 +1,142 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiscoverInputFiles_Glob(t *testing.T) {
+	repo := newTestRepo(t)
+	writeFiles(t, repo, map[string]string{
+		"api/api.repo-config.json":          `{}`,
+		"worker/worker.repo-config.yaml":    `{}`,
+		"worker/settings.json":              `{}`,
+		"node_modules/x/x.repo-config.json": `{}`,
+	})
+
+	files, err := DiscoverInputFiles(filepath.Join(repo, "worker"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expected := []string{
+		filepath.Join(repo, "api", "api.repo-config.json"),
+		filepath.Join(repo, "worker", "worker.repo-config.yaml"),
+	}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("Expected %v, got %v", expected, files)
+	}
+}
+
+func TestDiscoverInputFiles_Manifest(t *testing.T) {
+	repo := newTestRepo(t)
+	writeFiles(t, repo, map[string]string{
+		".repo-config.yaml":                "inputs:\n  - worker/settings.json\n  - services/*/config.yaml\n",
+		"worker/settings.json":             `{}`,
+		"services/a/config.yaml":           `{}`,
+		"services/b/config.yaml":           `{}`,
+		"ignored/ignored.repo-config.json": `{}`,
+	})
+
+	files, err := DiscoverInputFiles(repo)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expected := []string{
+		filepath.Join(repo, "worker", "settings.json"),
+		filepath.Join(repo, "services", "a", "config.yaml"),
+		filepath.Join(repo, "services", "b", "config.yaml"),
+	}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("Expected %v, got %v", expected, files)
+	}
+
+	writeFiles(t, repo, map[string]string{".repo-config.yaml": "inputs:\n  - missing.json\n"})
+	if _, err := DiscoverInputFiles(repo); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("Expected error for missing manifest entry, got %v", err)
+	}
+
+	writeFiles(t, filepath.Dir(repo), map[string]string{filepath.Base(repo) + "-other/config.yaml": `{}`})
+	writeFiles(t, repo, map[string]string{".repo-config.yaml": "inputs:\n  - ../*/config.yaml\n"})
+	if _, err := DiscoverInputFiles(repo); err == nil || !strings.Contains(err.Error(), "outside the repository root") {
+		t.Errorf("Expected error for a manifest entry outside the repository, got %v", err)
+	}
+}
+
+func TestCollectAllConfigs(t *testing.T) {
+	repo := newTestRepo(t)
+
+	originalHome := os.Getenv("HOME")
+	os.Setenv("HOME", repo)
+	defer os.Setenv("HOME", originalHome)
+
+	writeFiles(t, repo, map[string]string{
+		// generated values don't need a prompt, so silent mode can finish on its own
+		"good.repo-config.json": `{"id": {"description": "id", "generate": {"kind": "uuid"}}}`,
+		"bad.repo-config.json":  `{invalid`,
+	})
+
+	results, err := CollectAllConfigs(repo, true)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %v", results)
+	}
+	if results[0].Status != StatusError || !strings.HasSuffix(results[0].InputFile, "bad.repo-config.json") {
+		t.Errorf("Expected bad.repo-config.json to fail, got %+v", results[0])
+	}
+	if results[1].Status != StatusOK || results[1].JSONFile == "" {
+		t.Errorf("Expected good.repo-config.json to succeed, got %+v", results[1])
+	}
+
+	var output StatusOutput
+	if err := json.Unmarshal([]byte(CreateAggregateOutput(results)), &output); err != nil {
+		t.Fatalf("Failed to parse aggregate output: %v", err)
+	}
+	if output.Status != StatusError || len(output.Results) != 2 {
+		t.Errorf("Expected an error status with 2 results, got %+v", output)
+	}
+}
+
+func TestFindInputFile(t *testing.T) {
+	repo := newTestRepo(t)
+	writeFiles(t, repo, map[string]string{
+		"repo-config.json":               `{}`,
+		".repo-config.yaml":              "inputs: []\n",
+		"api/handlers/handler.go":        "",
+		"worker/worker.repo-config.yaml": `{}`,
+		"twice/a.repo-config.json":       `{}`,
+		"twice/b.repo-config.json":       `{}`,
+		"worker/jobs/README.md":          "",
+	})
+
+	tests := map[string]string{
+		"":             filepath.Join(repo, "repo-config.json"),
+		"api/handlers": filepath.Join(repo, "repo-config.json"),
+		"worker/jobs":  filepath.Join(repo, "worker", "worker.repo-config.yaml"),
+	}
+	for dir, expected := range tests {
+		found, err := FindInputFile(filepath.Join(repo, dir))
+		if err != nil {
+			t.Errorf("%s: expected no error, got %v", dir, err)
+			continue
+		}
+		if found != expected {
+			t.Errorf("%s: expected %s, got %s", dir, expected, found)
+		}
+	}
+
+	if _, err := FindInputFile(filepath.Join(repo, "twice")); err == nil || !strings.Contains(err.Error(), "several input files") {
+		t.Errorf("Expected error for several input files, got %v", err)
+	}
+
+	// The search stops at the repository root.
+	nested := newTestRepo(t)
+	if _, err := FindInputFile(nested); err == nil {
+		t.Error("Expected error when no input file exists, got nil")
+	}
+}
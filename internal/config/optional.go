@@ -0,0 +1,60 @@
+package config
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// emptyValuesKey is a reserved entry in the values file listing the settings whose
+// empty value was chosen on purpose, as a JSON array.  Without it an empty value is
+// indistinguishable from one that was never entered.
+const emptyValuesKey = "$emptyValues"
+
+// emptyValueInput is what the user types in the interactive prompt to set a value to
+// empty on purpose.
+const emptyValueInput = "<empty>"
+
+// isRequired reports whether the item must have a value.  Items are required unless
+// the input file says "required": false.
+func (item ItemConfig) isRequired() bool {
+	return item.Required == nil || *item.Required
+}
+
+// isMissing reports whether the item still needs a value from the user.
+func (item ItemConfig) isMissing() bool {
+	return item.Default == "" && item.isRequired() && !item.EmptyIsValid
+}
+
+// parseEmptyValues returns the setting names of the emptyValuesKey entry as a set.
+// Older versions wrote the names joined by commas instead of as an array.
+func parseEmptyValues(raw json.RawMessage) (map[string]bool, error) {
+	var names []string
+	if err := json.Unmarshal(raw, &names); err != nil {
+		var joined string
+		if json.Unmarshal(raw, &joined) != nil {
+			return nil, err
+		}
+		names = strings.Split(joined, ",")
+	}
+	keys := map[string]bool{}
+	for _, key := range names {
+		if key != "" {
+			keys[key] = true
+		}
+	}
+	return keys, nil
+}
+
+// formatEmptyValues returns the names for the emptyValuesKey entry of configMap,
+// sorted, or nil if no setting is empty on purpose.
+func formatEmptyValues(configMap map[string]ItemConfig) []string {
+	var keys []string
+	for key, item := range configMap {
+		if item.EmptyIsValid && item.Default == "" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
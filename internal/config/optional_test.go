@@ -0,0 +1,156 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckForMissingValues_Optional(t *testing.T) {
+	notRequired := false
+	configMap := map[string]ItemConfig{
+		"optional": {Required: &notRequired},
+		"cleared":  {EmptyIsValid: true},
+		"required": {},
+	}
+
+	missingValues := checkForMissingValues(configMap)
+	if len(missingValues) != 1 || missingValues[0] != "required" {
+		t.Errorf("Expected only 'required' to be missing, got %v", missingValues)
+	}
+}
+
+func TestEmptyValuesAreRecorded(t *testing.T) {
+	dir, err := os.MkdirTemp("", "config_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	originalHome := os.Getenv("HOME")
+	os.Setenv("HOME", dir)
+	defer os.Setenv("HOME", originalHome)
+
+	inputJSON := `{
+		"proxy": {
+			"description": "HTTP proxy",
+			"default": "http://proxy",
+			"tempEnvironmentVariableName": "HTTP_PROXY"
+		},
+		"extraFlags": {
+			"description": "Extra flags",
+			"required": false,
+			"tempEnvironmentVariableName": "EXTRA_FLAGS"
+		}
+	}`
+	inputJSONPath := filepath.Join(dir, "optional.json")
+	if err := os.WriteFile(inputJSONPath, []byte(inputJSON), 0644); err != nil {
+		t.Fatalf("Failed to write input JSON: %v", err)
+	}
+
+	s, err := newSession(inputJSONPath)
+	if err != nil {
+		t.Fatalf("Failed to open session: %v", err)
+	}
+
+	// Clear the required proxy setting (first in the file) on purpose and save.
+	userInput := bytes.NewBufferString("1\n" + emptyValueInput + "\ns\nc\n")
+	err = s.prompt(userInput)
+	s.close()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	values := readValues(t, inputJSONPath)
+	if values["proxy"] != "" || values[emptyValuesKey] != `["proxy"]` {
+		t.Errorf("Expected proxy to be recorded as empty, got %v", values)
+	}
+
+	// Reload: neither setting is missing now.
+	configMap, err := loadConfigFile(inputJSONPath)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	jsonOutputFile, envOutputFile, _ := GetOutputFilePaths(inputJSONPath)
+	existingValues, err := loadExistingValues(configMap, jsonOutputFile)
+	if err != nil {
+		t.Fatalf("Failed to load existing values: %v", err)
+	}
+	updateConfigMapWithExistingValues(configMap, existingValues)
+	if missing := checkForMissingValues(configMap); len(missing) != 0 {
+		t.Errorf("Expected no missing values, got %v", missing)
+	}
+	if compareConfigs(configMap, existingValues) {
+		t.Error("Expected the empty values marker not to count as a deleted setting")
+	}
+
+	// Blank values are still written to the env file.
+	envContent, err := os.ReadFile(envOutputFile)
+	if err != nil {
+		t.Fatalf("Failed to read .env output file: %v", err)
+	}
+	for _, line := range []string{"HTTP_PROXY=", "EXTRA_FLAGS="} {
+		if !strings.Contains(string(envContent), line) {
+			t.Errorf("Expected '%s' in env file, got '%s'", line, envContent)
+		}
+	}
+}
+
+func TestEmptyValuesKeepNamesWithCommas(t *testing.T) {
+	dir := t.TempDir()
+	jsonOutputFile := filepath.Join(dir, "values.json")
+	configMap := map[string]ItemConfig{
+		"a,b": {EmptyIsValid: true, Order: 0},
+		"a":   {Order: 1},
+		"b":   {Default: "x", Order: 2},
+	}
+	if err := saveConfigTo(jsonOutputFile, filepath.Join(dir, "values.env"), configMap); err != nil {
+		t.Fatalf("Failed to save: %v", err)
+	}
+	content, _ := os.ReadFile(jsonOutputFile)
+	if !strings.Contains(string(content), `"$emptyValues": ["a,b"]`) {
+		t.Errorf("Expected the empty values as a JSON array, got %s", content)
+	}
+
+	reloaded := map[string]ItemConfig{"a,b": {}, "a": {}, "b": {}}
+	existingValues, err := loadExistingValues(reloaded, jsonOutputFile)
+	if err != nil {
+		t.Fatalf("Failed to load existing values: %v", err)
+	}
+	updateConfigMapWithExistingValues(reloaded, existingValues)
+	if !reloaded["a,b"].EmptyIsValid || reloaded["a"].EmptyIsValid {
+		t.Errorf("Expected only 'a,b' to be empty on purpose, got %v", reloaded)
+	}
+}
+
+func TestEmptyValuesReadsCommaJoinedNames(t *testing.T) {
+	jsonOutputFile := filepath.Join(t.TempDir(), "values.json")
+	if err := os.WriteFile(jsonOutputFile, []byte(`{"proxy": "", "other": "", "$emptyValues": "proxy,other"}`), 0644); err != nil {
+		t.Fatalf("Failed to write values: %v", err)
+	}
+	configMap := map[string]ItemConfig{"proxy": {}, "other": {}}
+	existingValues, err := loadExistingValues(configMap, jsonOutputFile)
+	if err != nil {
+		t.Fatalf("Failed to load existing values: %v", err)
+	}
+	updateConfigMapWithExistingValues(configMap, existingValues)
+	if !configMap["proxy"].EmptyIsValid || !configMap["other"].EmptyIsValid {
+		t.Errorf("Expected the comma-joined names of older versions to be read, got %v", configMap)
+	}
+}
+
+func TestClearedGeneratedValueIsNotRegenerated(t *testing.T) {
+	configMap := map[string]ItemConfig{
+		"cleared": {Generate: &GenerateSpec{Kind: GenerateKindPassword}, EmptyIsValid: true},
+		"missing": {Generate: &GenerateSpec{Kind: GenerateKindPassword}},
+	}
+	generated, err := generateMissingValues(configMap)
+	if err != nil {
+		t.Fatalf("Failed to generate: %v", err)
+	}
+	if len(generated) != 1 || generated[0] != "missing" || configMap["cleared"].Default != "" {
+		t.Errorf("Expected only 'missing' to be generated, got %v", generated)
+	}
+}
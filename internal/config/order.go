@@ -0,0 +1,100 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// ungroupedHeading is the heading of the items without a group when they follow a
+// group in the interactive table.
+const ungroupedHeading = "other"
+
+// groupHeading returns the heading to show before item when the previous item shown
+// was in group, and whether one is needed at all.
+func groupHeading(group string, item ItemConfig) (string, bool) {
+	if item.Group == group {
+		return "", false
+	}
+	if item.Group == "" {
+		return ungroupedHeading, true
+	}
+	return item.Group, true
+}
+
+// applySectionAlias sets the group of item from "section", which input files may use
+// instead of "group", if raw, the item's JSON, has it.
+func applySectionAlias(raw json.RawMessage, item *ItemConfig) error {
+	var fields struct {
+		Group   *string `json:"group"`
+		Section *string `json:"section"`
+	}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return err
+	}
+	if fields.Section == nil {
+		return nil
+	}
+	if fields.Group != nil && *fields.Group != *fields.Section {
+		return fmt.Errorf("group '%s' and section '%s' differ: use one of them", *fields.Group, *fields.Section)
+	}
+	item.Group = *fields.Section
+	return nil
+}
+
+// orderedKeys returns the keys of configMap in display order: items are kept together
+// with the rest of their group, groups appear in the order they were first declared,
+// and items within a group keep their declaration order.  Items that were not decoded
+// from a file (Order 0) fall back to alphabetical order.
+func orderedKeys(configMap map[string]ItemConfig) []string {
+	keys := make([]string, 0, len(configMap))
+	groupRank := map[string]int{}
+	for key, item := range configMap {
+		keys = append(keys, key)
+		if rank, exists := groupRank[item.Group]; !exists || item.Order < rank {
+			groupRank[item.Group] = item.Order
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := configMap[keys[i]], configMap[keys[j]]
+		if a.Group != b.Group {
+			if groupRank[a.Group] != groupRank[b.Group] {
+				return groupRank[a.Group] < groupRank[b.Group]
+			}
+			return a.Group < b.Group
+		}
+		if a.Order != b.Order {
+			return a.Order < b.Order
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// marshalOrderedValues renders the values file with keys in the given order, so that
+// the file only changes when a value does.
+func marshalOrderedValues(keys []string, values map[string]interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("{")
+	for i, key := range keys {
+		keyJSON, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		valueJSON, err := json.Marshal(values[key])
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		fmt.Fprintf(&buf, "\n    %s: %s", keyJSON, valueJSON)
+	}
+	if len(keys) > 0 {
+		buf.WriteString("\n")
+	}
+	buf.WriteString("}\n")
+	return buf.Bytes(), nil
+}
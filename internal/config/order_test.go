@@ -0,0 +1,136 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadConfigFilePreservesOrder(t *testing.T) {
+	dir, err := os.MkdirTemp("", "config_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	inputJSON := `{
+		"zeta":  {"description": "Zeta"},
+		"cosmosKey": {"description": "Cosmos key", "group": "Cosmos"},
+		"alpha": {"description": "Alpha"},
+		"cosmosUrl": {"description": "Cosmos url", "group": "Cosmos"},
+		"blobKey": {"description": "Blob key", "group": "Blob"}
+	}`
+	inputJSONPath := filepath.Join(dir, "ordered.json")
+	if err := os.WriteFile(inputJSONPath, []byte(inputJSON), 0644); err != nil {
+		t.Fatalf("Failed to write input JSON: %v", err)
+	}
+
+	configMap, err := loadConfigFile(inputJSONPath)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := []string{"zeta", "alpha", "cosmosKey", "cosmosUrl", "blobKey"}
+	if keys := orderedKeys(configMap); !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Expected keys %v, got %v", expected, keys)
+	}
+}
+
+func TestLoadConfigFileDuplicateKey(t *testing.T) {
+	dir, err := os.MkdirTemp("", "config_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	inputJSONPath := filepath.Join(dir, "duplicate.json")
+	if err := os.WriteFile(inputJSONPath, []byte(`{"a": {}, "a": {}}`), 0644); err != nil {
+		t.Fatalf("Failed to write input JSON: %v", err)
+	}
+	if _, err := loadConfigFile(inputJSONPath); err == nil {
+		t.Error("Expected error for duplicate setting, got nil")
+	}
+}
+
+func TestSaveConfigIsDeterministic(t *testing.T) {
+	dir, err := os.MkdirTemp("", "config_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	originalHome := os.Getenv("HOME")
+	os.Setenv("HOME", dir)
+	defer os.Setenv("HOME", originalHome)
+
+	inputJSONFile := filepath.Join(dir, "ordered-config.json")
+	if err := os.WriteFile(inputJSONFile, []byte("{}"), 0644); err != nil {
+		t.Fatalf("Failed to write input JSON file: %v", err)
+	}
+
+	configMap := map[string]ItemConfig{
+		"second": {Default: "2", TempEnvironmentVariableName: "SECOND", Order: 2},
+		"first":  {Default: "1", TempEnvironmentVariableName: "FIRST", Order: 1},
+		"third":  {Default: "3", TempEnvironmentVariableName: "THIRD", Order: 3},
+	}
+	saveTestValues(t, inputJSONFile, configMap)
+
+	jsonOutputFile, envOutputFile, _ := GetOutputFilePaths(inputJSONFile)
+	jsonContent, err := os.ReadFile(jsonOutputFile)
+	if err != nil {
+		t.Fatalf("Failed to read JSON output file: %v", err)
+	}
+	expectedJSON := "{\n    \"first\": \"1\",\n    \"second\": \"2\",\n    \"third\": \"3\"\n}\n"
+	if string(jsonContent) != expectedJSON {
+		t.Errorf("Expected JSON content %q, got %q", expectedJSON, jsonContent)
+	}
+
+	envContent, err := os.ReadFile(envOutputFile)
+	if err != nil {
+		t.Fatalf("Failed to read .env output file: %v", err)
+	}
+	expectedEnv := "FIRST=1\nSECOND=2\nTHIRD=3"
+	if string(envContent) != expectedEnv {
+		t.Errorf("Expected env content %q, got %q", expectedEnv, envContent)
+	}
+}
+
+func TestGroupHeading(t *testing.T) {
+	for _, test := range []struct {
+		group, itemGroup, heading string
+		changed                   bool
+	}{
+		{"", "", "", false},
+		{"", "Cosmos", "Cosmos", true},
+		{"Cosmos", "Cosmos", "", false},
+		{"Cosmos", "", ungroupedHeading, true},
+	} {
+		heading, changed := groupHeading(test.group, ItemConfig{Group: test.itemGroup})
+		if heading != test.heading || changed != test.changed {
+			t.Errorf("Expected (%q, %v) for %q after %q, got (%q, %v)", test.heading, test.changed, test.itemGroup, test.group, heading, changed)
+		}
+	}
+}
+
+func TestLoadConfigFileSectionAlias(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"sections.json": `{
+			"cosmosKey": {"description": "Cosmos key", "section": "Cosmos"},
+			"blobKey": {"description": "Blob key", "group": "Blob", "section": "Blob"}
+		}`,
+		"conflict.json": `{"key": {"group": "a", "section": "b"}}`,
+	})
+
+	configMap, err := loadConfigFile(filepath.Join(dir, "sections.json"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if configMap["cosmosKey"].Group != "Cosmos" || configMap["blobKey"].Group != "Blob" {
+		t.Errorf("Expected section to set the group, got %v", configMap)
+	}
+	if _, err := loadConfigFile(filepath.Join(dir, "conflict.json")); err == nil {
+		t.Error("Expected an error for a group and section that differ")
+	}
+}
@@ -0,0 +1,121 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// outputPathSeparator joins the directories of an input file's path in the names of
+// its output files, e.g. api/settings.json -> .api__settings-values.json.
+const outputPathSeparator = "__"
+
+// migratedSuffix is appended to output files that were copied to their namespaced
+// names.  It keeps them from being sourced while letting other input files with the
+// same base name migrate from them too.
+const migratedSuffix = ".migrated"
+
+// outputName returns the name the output files of absInputFile are derived from: its
+// path relative to the root of the project's Git repository, without the extension.
+// Input files at the root, or outside a repository, keep their plain base name.
+func outputName(project projectInfo, absInputFile string) string {
+	name := outputBaseName(absInputFile)
+	if project.root == "" {
+		return name
+	}
+	rel, err := filepath.Rel(project.root, project.dir)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return name
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	return strings.Join(append(parts, name), outputPathSeparator)
+}
+
+// inputExtensions are the extensions of the input file formats.
+var inputExtensions = []string{".json", ".jsonc", ".yaml", ".yml"}
+
+// migrateLegacyOutputFiles moves output files named after the input's base name only
+// (as older versions did) to their namespaced names.  Several input files with the same
+// base name used to share those files, so each one copies them, and the originals are
+// renamed with migratedSuffix rather than deleted.  Files that an input file at the
+// root of the repo still uses under that name are left alone.  Callers hold the
+// project's lock.
+func migrateLegacyOutputFiles(files outputFiles) error {
+	if files.legacyName == files.name || ownedByRootInput(files.project, files.legacyName) {
+		return nil
+	}
+	outputDir, legacyName, name := filepath.Dir(files.json), files.legacyName, files.name
+	for _, ext := range []string{"json", "env"} {
+		target := filepath.Join(outputDir, fmt.Sprintf(".%s-values.%s", name, ext))
+		if _, err := os.Stat(target); err == nil {
+			continue
+		}
+
+		legacy := filepath.Join(outputDir, fmt.Sprintf(".%s-values.%s", legacyName, ext))
+		source := legacy
+		if _, err := os.Stat(source); err != nil {
+			source = legacy + migratedSuffix
+			if _, err := os.Stat(source); err != nil {
+				continue
+			}
+		}
+
+		data, err := os.ReadFile(source)
+		if err != nil {
+			return fmt.Errorf("failed to read '%s': %v", source, err)
+		}
+		if err := os.WriteFile(target, data, valuesFileMode); err != nil {
+			return fmt.Errorf("failed to write '%s': %v", target, err)
+		}
+		if source == legacy {
+			if err := os.Rename(legacy, legacy+migratedSuffix); err != nil {
+				return fmt.Errorf("failed to rename '%s': %v", legacy, err)
+			}
+		}
+		fmt.Fprintf(os.Stderr, "Copied values from %s to %s\n", source, target)
+	}
+	return nil
+}
+
+// ownedByRootInput reports whether an input file at the root of project's repo has the
+// output files named name, so they are not legacy ones.
+func ownedByRootInput(project projectInfo, name string) bool {
+	if project.root == "" {
+		return false
+	}
+	for _, ext := range inputExtensions {
+		if _, err := os.Stat(filepath.Join(project.root, name+ext)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// ListConfigs returns the output files of every input file of the repository
+// containing startDir.  Inputs whose values haven't been collected yet say so in
+// their message.
+func ListConfigs(startDir string) ([]StatusOutput, error) {
+	inputFiles, err := DiscoverInputFiles(startDir)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []StatusOutput{}
+	for _, inputFile := range inputFiles {
+		var result StatusOutput
+		// Listing is read-only: locate the files without creating or migrating them.
+		files, err := locateOutputFiles(inputFile)
+		if err != nil {
+			result = newErrorStatus(err)
+		} else {
+			result = newSuccessStatus(files.json, files.env)
+			if _, err := os.Stat(files.json); os.IsNotExist(err) {
+				result.Message = "not collected yet"
+			}
+		}
+		result.InputFile = inputFile
+		results = append(results, result)
+	}
+	return results, nil
+}
@@ -0,0 +1,173 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetOutputFilePathsNamespacesByPath(t *testing.T) {
+	repo := newTestRepo(t)
+
+	originalHome := os.Getenv("HOME")
+	os.Setenv("HOME", repo)
+	defer os.Setenv("HOME", originalHome)
+
+	writeFiles(t, repo, map[string]string{
+		"settings.json":                 `{}`,
+		"api/settings.json":             `{}`,
+		"services/worker/settings.yaml": `{}`,
+	})
+
+	rootJSON, _, err := GetOutputFilePaths(filepath.Join(repo, "settings.json"))
+	if err != nil {
+		t.Fatalf("Failed to get output file paths: %v", err)
+	}
+	apiJSON, apiEnv, err := GetOutputFilePaths(filepath.Join(repo, "api", "settings.json"))
+	if err != nil {
+		t.Fatalf("Failed to get output file paths: %v", err)
+	}
+	workerJSON, _, err := GetOutputFilePaths(filepath.Join(repo, "services", "worker", "settings.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to get output file paths: %v", err)
+	}
+
+	expected := map[string]string{
+		rootJSON:   ".settings-values.json",
+		apiJSON:    ".api__settings-values.json",
+		apiEnv:     ".api__settings-values.env",
+		workerJSON: ".services__worker__settings-values.json",
+	}
+	for path, name := range expected {
+		if filepath.Base(path) != name {
+			t.Errorf("Expected %s, got %s", name, filepath.Base(path))
+		}
+	}
+}
+
+func TestNewSessionMigratesLegacyFiles(t *testing.T) {
+	repo := newTestRepo(t)
+
+	originalHome := os.Getenv("HOME")
+	os.Setenv("HOME", repo)
+	defer os.Setenv("HOME", originalHome)
+
+	writeFiles(t, repo, map[string]string{
+		"api/settings.json":    `{}`,
+		"worker/settings.json": `{}`,
+	})
+
+	// Find the project directory and write the files an older version would have.
+	apiJSON, apiEnv, err := GetOutputFilePaths(filepath.Join(repo, "api", "settings.json"))
+	if err != nil {
+		t.Fatalf("Failed to get output file paths: %v", err)
+	}
+	workerJSON, _, err := GetOutputFilePaths(filepath.Join(repo, "worker", "settings.json"))
+	if err != nil {
+		t.Fatalf("Failed to get output file paths: %v", err)
+	}
+	outputDir := filepath.Dir(apiJSON)
+	legacyJSON := filepath.Join(outputDir, ".settings-values.json")
+	legacyEnv := filepath.Join(outputDir, ".settings-values.env")
+	writeFiles(t, outputDir, map[string]string{
+		".settings-values.json": `{"shared": "value"}`,
+		".settings-values.env":  "SHARED=value",
+	})
+	if _, err := os.Stat(apiJSON); !os.IsNotExist(err) {
+		t.Fatalf("Expected getting the output file paths not to migrate %s", apiJSON)
+	}
+
+	for _, inputFile := range []string{"api/settings.json", "worker/settings.json"} {
+		s, err := newSession(filepath.Join(repo, inputFile))
+		if err != nil {
+			t.Fatalf("Failed to load %s: %v", inputFile, err)
+		}
+		s.close()
+	}
+
+	for _, path := range []string{apiJSON, workerJSON} {
+		content, err := os.ReadFile(path)
+		if err != nil || string(content) != `{"shared": "value"}` {
+			t.Errorf("Expected legacy values in %s, got %q (%v)", path, content, err)
+		}
+	}
+	if content, err := os.ReadFile(apiEnv); err != nil || string(content) != "SHARED=value" {
+		t.Errorf("Expected legacy env in %s, got %q (%v)", apiEnv, content, err)
+	}
+	for _, path := range []string{legacyJSON, legacyEnv} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be renamed", path)
+		}
+		if _, err := os.Stat(path + migratedSuffix); err != nil {
+			t.Errorf("Expected %s%s to exist", path, migratedSuffix)
+		}
+	}
+}
+
+func TestNewSessionKeepsFilesOfRootInput(t *testing.T) {
+	repo := newTestRepo(t)
+
+	originalHome := os.Getenv("HOME")
+	os.Setenv("HOME", repo)
+	defer os.Setenv("HOME", originalHome)
+
+	writeFiles(t, repo, map[string]string{
+		"settings.json":        `{"key": {"description": "Key", "default": ""}}`,
+		"worker/settings.json": `{}`,
+	})
+	rootJSON, _, err := GetOutputFilePaths(filepath.Join(repo, "settings.json"))
+	if err != nil {
+		t.Fatalf("Failed to get output file paths: %v", err)
+	}
+	writeFiles(t, filepath.Dir(rootJSON), map[string]string{".settings-values.json": `{"key": "value"}`})
+
+	s, err := newSession(filepath.Join(repo, "worker", "settings.json"))
+	if err != nil {
+		t.Fatalf("Failed to load worker/settings.json: %v", err)
+	}
+	s.close()
+	if _, err := os.Stat(s.jsonOutputFile); !os.IsNotExist(err) {
+		t.Errorf("Expected the values of settings.json not to be copied to %s", s.jsonOutputFile)
+	}
+
+	s, err = newSession(filepath.Join(repo, "settings.json"))
+	if err != nil {
+		t.Fatalf("Failed to load settings.json: %v", err)
+	}
+	s.close()
+	if s.configMap["key"].Default != "value" {
+		t.Errorf("Expected settings.json to keep its values, got %q", s.configMap["key"].Default)
+	}
+}
+
+func TestListConfigs(t *testing.T) {
+	repo := newTestRepo(t)
+
+	originalHome := os.Getenv("HOME")
+	os.Setenv("HOME", repo)
+	defer os.Setenv("HOME", originalHome)
+
+	writeFiles(t, repo, map[string]string{
+		"api/settings.repo-config.json":    `{}`,
+		"worker/settings.repo-config.json": `{}`,
+	})
+
+	results, err := ListConfigs(repo)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %v", results)
+	}
+	if results[0].JSONFile == results[1].JSONFile {
+		t.Errorf("Expected different output files, got %s twice", results[0].JSONFile)
+	}
+	for _, result := range results {
+		if result.Status != StatusOK || result.Message != "not collected yet" {
+			t.Errorf("Unexpected result %+v", result)
+		}
+		if _, err := os.Stat(filepath.Dir(result.JSONFile)); !os.IsNotExist(err) {
+			t.Errorf("Expected list not to create %s, got %v", filepath.Dir(result.JSONFile), err)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// SourcePlugin is the scheme of values resolved by a provider plugin:
+// "plugin:<name>:<ref>" runs the executable repo-config-provider-<name> found on PATH
+// and asks it for the value of ref, which only the plugin understands.
+//
+// A plugin is run once per request.  It reads one JSON request from stdin and writes
+// one JSON response to stdout; stderr is passed through, so it can tell the user
+// what it is doing, e.g. how to sign in.  Requests are
+//
+//	{"version": 1, "operation": "capabilities"}
+//	{"version": 1, "operation": "resolve", "key": "dbPassword", "ref": "team/db#password", "description": "..."}
+//	{"version": 1, "operation": "list", "ref": "team/"}
+//
+// and the responses
+//
+//	{"version": 1, "operations": ["resolve", "list"]}
+//	{"value": "s3cret"}
+//	{"refs": ["team/db#password", "team/db#user"]}
+//	{"error": {"code": "permission_denied", "message": "..."}}
+//
+// capabilities is asked first, once per run, and must be supported by every plugin.
+// It may run for the scriptTimeout setting.
+const SourcePlugin = "plugin"
+
+// pluginExecutablePrefix is the prefix of the executable of a plugin, before its name.
+const pluginExecutablePrefix = "repo-config-provider-"
+
+// pluginProtocolVersion is the version of the protocol described at SourcePlugin.
+const pluginProtocolVersion = 1
+
+// Operations of the plugin protocol.
+const (
+	PluginOperationCapabilities = "capabilities"
+	PluginOperationResolve      = "resolve"
+	PluginOperationList         = "list"
+)
+
+// pluginNamePattern matches plugin names, which become part of an executable's name.
+var pluginNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+// pluginRequest is a request of the plugin protocol.
+type pluginRequest struct {
+	Version     int    `json:"version"`
+	Operation   string `json:"operation"`
+	Key         string `json:"key,omitempty"`
+	Ref         string `json:"ref,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// pluginResponse is a response of the plugin protocol, for any operation.
+type pluginResponse struct {
+	Version    int          `json:"version,omitempty"`
+	Operations []string     `json:"operations,omitempty"`
+	Value      *string      `json:"value,omitempty"`
+	Refs       []string     `json:"refs,omitempty"`
+	Error      *pluginError `json:"error,omitempty"`
+}
+
+// pluginError is a failure reported by a plugin, or running it.
+type pluginError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *pluginError) Error() string {
+	return e.Message
+}
+
+func (e *pluginError) errorCode() string {
+	return e.Code
+}
+
+// pluginProvider resolves values with plugins.  Each plugin's capabilities and each
+// value are asked for once per run.
+type pluginProvider struct {
+	operations map[string][]string
+	values     map[string]string
+}
+
+// newPluginProvider returns a plugin provider with an empty cache.
+func newPluginProvider() *pluginProvider {
+	return &pluginProvider{
+		operations: make(map[string][]string),
+		values:     make(map[string]string),
+	}
+}
+
+func (p *pluginProvider) Resolve(ctx context.Context, key string, item ItemConfig) (string, error) {
+	_, source := parseSource(item.Source)
+	name, ref, found := strings.Cut(source, ":")
+	if !found || ref == "" {
+		return "", fmt.Errorf("expected 'plugin:<name>:<ref>'")
+	}
+	if value, exists := p.values[source]; exists {
+		return value, nil
+	}
+	if err := p.require(ctx, name, PluginOperationResolve); err != nil {
+		return "", err
+	}
+	response, err := callPlugin(ctx, name, pluginRequest{Operation: PluginOperationResolve, Key: key, Ref: ref, Description: item.Description})
+	if err != nil {
+		return "", err
+	}
+	if response.Value == nil {
+		return "", &pluginError{Code: "invalid_response", Message: fmt.Sprintf("plugin '%s' returned no value", name)}
+	}
+	p.values[source] = *response.Value
+	return *response.Value, nil
+}
+
+// require returns an error unless the plugin name supports operation.
+func (p *pluginProvider) require(ctx context.Context, name, operation string) error {
+	operations, exists := p.operations[name]
+	if !exists {
+		var err error
+		if operations, err = pluginCapabilities(ctx, name); err != nil {
+			return err
+		}
+		p.operations[name] = operations
+	}
+	if !slices.Contains(operations, operation) {
+		return &pluginError{Code: "unsupported", Message: fmt.Sprintf("plugin '%s' does not support %s", name, operation)}
+	}
+	return nil
+}
+
+// PluginCapabilities returns the operations the plugin name supports.
+func PluginCapabilities(name string) ([]string, error) {
+	return pluginCapabilities(context.Background(), name)
+}
+
+// pluginCapabilities asks the plugin name for the operations it supports, after
+// checking that it speaks this version of the protocol.
+func pluginCapabilities(ctx context.Context, name string) ([]string, error) {
+	response, err := callPlugin(ctx, name, pluginRequest{Operation: PluginOperationCapabilities})
+	if err != nil {
+		return nil, err
+	}
+	if response.Version != pluginProtocolVersion {
+		return nil, &pluginError{Code: "unsupported", Message: fmt.Sprintf("plugin '%s' speaks protocol version %d; expected %d", name, response.Version, pluginProtocolVersion)}
+	}
+	return response.Operations, nil
+}
+
+// ListPluginRefs returns the refs the plugin name can resolve that start with prefix,
+// for plugins that support listing them.
+func ListPluginRefs(name, prefix string) ([]string, error) {
+	ctx := context.Background()
+	if err := newPluginProvider().require(ctx, name, PluginOperationList); err != nil {
+		return nil, err
+	}
+	response, err := callPlugin(ctx, name, pluginRequest{Operation: PluginOperationList, Ref: prefix})
+	if err != nil {
+		return nil, err
+	}
+	if response.Refs == nil {
+		return []string{}, nil
+	}
+	return response.Refs, nil
+}
+
+// callPlugin runs the plugin name with request and returns its response.  Errors the
+// plugin reports, and failures running it, are pluginErrors.
+func callPlugin(ctx context.Context, name string, request pluginRequest) (*pluginResponse, error) {
+	if !pluginNamePattern.MatchString(name) {
+		return nil, &pluginError{Code: "invalid_source", Message: fmt.Sprintf("invalid plugin name '%s'", name)}
+	}
+	executable := pluginExecutablePrefix + name
+	path, err := exec.LookPath(executable)
+	if err != nil {
+		return nil, &pluginError{Code: "not_found", Message: fmt.Sprintf("plugin '%s' not found: install %s on PATH", name, executable)}
+	}
+
+	request.Version = pluginProtocolVersion
+	input, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	timeout := activeSettings.scriptTimeout()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stderr = os.Stderr
+	cmd.WaitDelay = processWaitDelay
+	output, runErr := cmd.Output()
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, &pluginError{Code: "timeout", Message: fmt.Sprintf("plugin '%s' timed out after %s", name, timeout)}
+	}
+
+	var response pluginResponse
+	if err := json.Unmarshal(output, &response); err != nil {
+		if runErr != nil {
+			return nil, &pluginError{Code: "failed", Message: fmt.Sprintf("plugin '%s' failed: %v", name, runErr)}
+		}
+		return nil, &pluginError{Code: "invalid_response", Message: fmt.Sprintf("plugin '%s' returned invalid JSON: %v", name, err)}
+	}
+	if response.Error != nil {
+		if response.Error.Code == "" {
+			response.Error.Code = "failed"
+		}
+		response.Error.Message = fmt.Sprintf("plugin '%s': %s", name, response.Error.Message)
+		return nil, response.Error
+	}
+	if runErr != nil {
+		return nil, &pluginError{Code: "failed", Message: fmt.Sprintf("plugin '%s' failed: %v", name, runErr)}
+	}
+	return &response, nil
+}
@@ -0,0 +1,106 @@
+//go:build unix
+
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testPlugin is a provider plugin that resolves team/db#password, refuses denied,
+// hangs on slow and logs every request to $PLUGIN_LOG.
+const testPlugin = `#!/bin/sh
+request=$(cat)
+echo "$request" >> "$PLUGIN_LOG"
+case "$request" in
+*'"operation":"capabilities"'*) echo '{"version": 1, "operations": ["capabilities", "resolve", "list"]}' ;;
+*'"operation":"list"'*) echo '{"refs": ["team/db#password"]}' ;;
+*'"ref":"team/db#password"'*) echo "checking the vault" >&2; echo '{"value": "s3cret"}' ;;
+*'"ref":"denied"'*) echo '{"error": {"code": "permission_denied", "message": "no access to denied"}}' ;;
+*'"ref":"slow"'*) exec sleep 10 ;;
+*) exit 3 ;;
+esac
+`
+
+// installTestPlugin puts testPlugin on PATH as the plugin "test" and returns the path
+// of its request log.
+func installTestPlugin(t *testing.T) string {
+	t.Helper()
+	bin := t.TempDir()
+	writeFiles(t, bin, map[string]string{
+		"repo-config-provider-test": testPlugin,
+		"repo-config-provider-old":  "#!/bin/sh\necho '{\"version\": 2}'\n",
+	})
+	for _, name := range []string{"repo-config-provider-test", "repo-config-provider-old"} {
+		if err := os.Chmod(filepath.Join(bin, name), 0755); err != nil {
+			t.Fatalf("Failed to make %s executable: %v", name, err)
+		}
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	log := filepath.Join(bin, "requests.log")
+	t.Setenv("PLUGIN_LOG", log)
+	return log
+}
+
+func TestPluginProvider(t *testing.T) {
+	log := installTestPlugin(t)
+	configMap := map[string]ItemConfig{
+		"dbPassword": {Source: "plugin:test:team/db#password", Order: 1},
+		"again":      {Source: "plugin:test:team/db#password", Order: 2},
+	}
+	if _, err := resolveValues(context.Background(), configMap, newProviders("repo-config.json", scriptRunner{}), scriptRunner{}); err != nil {
+		t.Fatalf("Failed to resolve sources: %v", err)
+	}
+	if configMap["dbPassword"].Default != "s3cret" || configMap["again"].Default != "s3cret" {
+		t.Errorf("Unexpected values %q and %q", configMap["dbPassword"].Default, configMap["again"].Default)
+	}
+	requests, _ := os.ReadFile(log)
+	if lines := strings.Split(strings.TrimSpace(string(requests)), "\n"); len(lines) != 2 ||
+		lines[1] != `{"version":1,"operation":"resolve","key":"dbPassword","ref":"team/db#password"}` {
+		t.Errorf("Expected capabilities and one resolve request, got %q", requests)
+	}
+
+	refs, err := ListPluginRefs("test", "team/")
+	if err != nil || strings.Join(refs, ",") != "team/db#password" {
+		t.Errorf("Unexpected refs %v (%v)", refs, err)
+	}
+}
+
+func TestPluginProviderErrors(t *testing.T) {
+	installTestPlugin(t)
+	settings := defaultSettings()
+	settings.ScriptTimeout = "200ms"
+	UseSettings(settings)
+	t.Cleanup(func() { UseSettings(defaultSettings()) })
+
+	configMap := map[string]ItemConfig{
+		"denied":  {Source: "plugin:test:denied", Order: 1},
+		"slow":    {Source: "plugin:test:slow", Order: 2},
+		"crash":   {Source: "plugin:test:crash", Order: 3},
+		"missing": {Source: "plugin:nope:ref", Order: 4},
+		"old":     {Source: "plugin:old:ref", Order: 5},
+	}
+	_, err := resolveValues(context.Background(), configMap, newProviders("repo-config.json", scriptRunner{}), scriptRunner{})
+	status := newErrorStatus(err)
+	expected := []SourceError{
+		{Key: "denied", Source: "plugin:test:denied", Code: "permission_denied", Message: "plugin 'test': no access to denied"},
+		{Key: "slow", Source: "plugin:test:slow", Code: "timeout", Message: "plugin 'test' timed out after 200ms"},
+		{Key: "crash", Source: "plugin:test:crash", Code: "failed", Message: "plugin 'test' failed: exit status 3"},
+		{Key: "missing", Source: "plugin:nope:ref", Code: "not_found", Message: "plugin 'nope' not found: install repo-config-provider-nope on PATH"},
+		{Key: "old", Source: "plugin:old:ref", Code: "unsupported", Message: "plugin 'old' speaks protocol version 2; expected 1"},
+	}
+	if len(status.Errors) != len(expected) {
+		t.Fatalf("Expected %d errors, got %s", len(expected), status)
+	}
+	for i := range expected {
+		if status.Errors[i] != expected[i] {
+			t.Errorf("Expected %+v, got %+v", expected[i], status.Errors[i])
+		}
+	}
+	if !strings.HasPrefix(status.Message, "failed to resolve 'denied' from 'plugin:test:denied': plugin 'test': no access to denied; ") {
+		t.Errorf("Unexpected message %s", status.Message)
+	}
+}
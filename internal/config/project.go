@@ -0,0 +1,141 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// projectInfo describes the project an input file belongs to.
+type projectInfo struct {
+	// dir is the directory of the input file, with symlinks resolved.
+	dir string
+	// root is the top-level directory of the Git repository, or "" outside one.
+	root string
+	// name is the normalized URL of the origin remote, so that the name survives
+	// renaming the clone and two repos with the same directory name don't share
+	// values.  Repos without a remote, and directories outside a repository, use
+	// alias with a hash of their path, so two of them with the same base name don't
+	// share values either.
+	name string
+	// alias is the base name of the project's directory: the Git root, or the
+	// current directory outside a repository.  It is what older versions used as
+	// the project name, and is kept as a friendlier name for the project's directory.
+	alias string
+}
+
+// resolveProject determines the project of absInputFile, walking up to the root of
+// its Git repository only once.
+func resolveProject(absInputFile string) projectInfo {
+	dir := filepath.Dir(absInputFile)
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = resolved
+	}
+
+	// TODO: have the container set an environment variable that can be used
+	// as the project name and also add a --project-name as an optional
+	// flag that will override this.
+	root, err := getGitRoot(dir)
+	if err != nil {
+		pwd := os.Getenv("PWD")
+		return projectInfo{dir: dir, name: localProjectName(pwd), alias: filepath.Base(pwd)}
+	}
+
+	project := projectInfo{dir: dir, root: root, name: localProjectName(root), alias: filepath.Base(root)}
+	if remote, err := getRemoteURL(root); err == nil && remote != "" {
+		if name := normalizeRemoteURL(remote); name != "" {
+			project.name = name
+		}
+	}
+	return project
+}
+
+// localProjectHashLength is how many hex digits of the hash of its path the name of a
+// project without a remote has.
+const localProjectHashLength = 12
+
+// localProjectName returns the name of the project in dir when it has no remote: the
+// base name of dir and a hash of its path, e.g. api-3f2a9c1b7d4e.
+func localProjectName(dir string) string {
+	sum := sha256.Sum256([]byte(filepath.Clean(dir)))
+	return filepath.Base(dir) + "-" + hex.EncodeToString(sum[:])[:localProjectHashLength]
+}
+
+// normalizeRemoteURL turns the different spellings of a remote into one name that is
+// safe to use as a directory, so that https and ssh clones of a repository share it:
+//
+//	git@github.com:joelong01/repo-config.git   -> github.com_joelong01_repo-config
+//	https://github.com/joelong01/repo-config    -> github.com_joelong01_repo-config
+func normalizeRemoteURL(remote string) string {
+	remote = strings.TrimSpace(remote)
+	host, path := "", remote
+	if parsed, err := url.Parse(remote); err == nil && parsed.Scheme != "" && parsed.Host != "" {
+		host, path = parsed.Hostname(), parsed.Path
+	} else if at, rest, found := strings.Cut(remote, "@"); found && !strings.Contains(at, "/") {
+		// scp-like syntax: user@host:path
+		host, path, _ = strings.Cut(rest, ":")
+	} else if before, after, found := strings.Cut(remote, ":"); found && !strings.Contains(before, "/") && len(before) > 1 {
+		// scp-like syntax without a user: host:path
+		host, path = before, after
+	}
+
+	path = strings.Trim(path, "/")
+	path = strings.TrimSuffix(path, ".git")
+	parts := []string{}
+	if host != "" {
+		parts = append(parts, strings.ToLower(host))
+	}
+	for _, part := range strings.Split(filepath.ToSlash(path), "/") {
+		if part != "" && part != "." && part != ".." {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "_")
+}
+
+// ensureProjectDir creates the directory for a project's values under storeDir and
+// returns it.  When alias differs from name:
+//   - a directory named alias that older versions created is moved to name, and
+//   - alias is made a symlink to name if nothing else uses it yet.
+//
+// A symlink named name is the alias of another project, so it is replaced by a
+// directory rather than shared.
+func ensureProjectDir(storeDir, name, alias string) (string, error) {
+	outputDir := filepath.Join(storeDir, name)
+	aliasDir := filepath.Join(storeDir, alias)
+
+	if info, err := os.Lstat(outputDir); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		if err := os.Remove(outputDir); err != nil {
+			return "", fmt.Errorf("failed to remove the alias '%s': %v", outputDir, err)
+		}
+	}
+
+	if alias != "" && alias != name {
+		if _, err := os.Lstat(outputDir); os.IsNotExist(err) {
+			if info, err := os.Lstat(aliasDir); err == nil && info.IsDir() {
+				if err := os.Rename(aliasDir, outputDir); err != nil {
+					return "", fmt.Errorf("failed to move '%s' to '%s': %v", aliasDir, outputDir, err)
+				}
+				fmt.Fprintf(os.Stderr, "Moved %s to %s\n", aliasDir, outputDir)
+			}
+		}
+	}
+
+	if _, err := os.Lstat(outputDir); os.IsNotExist(err) {
+		if err := os.MkdirAll(outputDir, 0755); err != nil {
+			return "", fmt.Errorf("failed to create directory '%s': %v", outputDir, err)
+		}
+	}
+
+	if alias != "" && alias != name {
+		if _, err := os.Lstat(aliasDir); os.IsNotExist(err) {
+			// The alias is a convenience, so failing to create it is not an error.
+			_ = os.Symlink(name, aliasDir)
+		}
+	}
+	return outputDir, nil
+}
@@ -0,0 +1,147 @@
+package config
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeRemoteURL(t *testing.T) {
+	tests := map[string]string{
+		"git@github.com:joelong01/repo-config.git":           "github.com_joelong01_repo-config",
+		"https://github.com/joelong01/repo-config":           "github.com_joelong01_repo-config",
+		"https://GitHub.com/joelong01/repo-config.git/":      "github.com_joelong01_repo-config",
+		"ssh://git@github.com:22/joelong01/repo-config.git":  "github.com_joelong01_repo-config",
+		"https://org@dev.azure.com/org/project/_git/service": "dev.azure.com_org_project__git_service",
+		"github.com:joelong01/repo-config":                   "github.com_joelong01_repo-config",
+		"/srv/git/repo-config.git":                           "srv_git_repo-config",
+		"../relative/../repo-config":                         "relative_repo-config",
+	}
+	for remote, expected := range tests {
+		if name := normalizeRemoteURL(remote); name != expected {
+			t.Errorf("%s: expected %s, got %s", remote, expected, name)
+		}
+	}
+}
+
+// git runs a git command in dir.
+func git(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	cmd.Dir = dir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v failed: %v: %s", args, err, output)
+	}
+}
+
+func TestGetOutputFilePathsUsesRemote(t *testing.T) {
+	repo := newTestRepo(t)
+	git(t, repo, "remote", "add", "origin", "git@github.com:joelong01/api.git")
+
+	home, err := os.MkdirTemp("", "config_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(home)
+
+	originalHome := os.Getenv("HOME")
+	os.Setenv("HOME", home)
+	defer os.Setenv("HOME", originalHome)
+
+	// Values written by an older version under the directory's base name.
+	storeDir := filepath.Join(home, ".repo-config")
+	alias := filepath.Base(repo)
+	writeFiles(t, filepath.Join(storeDir, alias), map[string]string{".settings-values.json": `{"key": "value"}`})
+
+	inputFile := filepath.Join(repo, "settings.json")
+	writeFiles(t, repo, map[string]string{"settings.json": `{}`})
+
+	jsonOutputFile, _, err := GetOutputFilePaths(inputFile)
+	if err != nil {
+		t.Fatalf("Failed to get output file paths: %v", err)
+	}
+	expected := filepath.Join(storeDir, "github.com_joelong01_api", ".settings-values.json")
+	if jsonOutputFile != expected {
+		t.Errorf("Expected %s, got %s", expected, jsonOutputFile)
+	}
+
+	// The old directory was moved and replaced by an alias.
+	if content, err := os.ReadFile(jsonOutputFile); err != nil || string(content) != `{"key": "value"}` {
+		t.Errorf("Expected migrated values, got %q (%v)", content, err)
+	}
+	if target, err := os.Readlink(filepath.Join(storeDir, alias)); err != nil || target != "github.com_joelong01_api" {
+		t.Errorf("Expected alias symlink to github.com_joelong01_api, got %q (%v)", target, err)
+	}
+
+	// A worktree of the same repository shares the values.
+	git(t, repo, "commit", "-q", "--allow-empty", "-m", "initial")
+	worktree := filepath.Join(home, "api-feature")
+	git(t, repo, "worktree", "add", "-q", worktree)
+	writeFiles(t, worktree, map[string]string{"settings.json": `{}`})
+	worktreeJSON, _, err := GetOutputFilePaths(filepath.Join(worktree, "settings.json"))
+	if err != nil {
+		t.Fatalf("Failed to get output file paths: %v", err)
+	}
+	if worktreeJSON != expected {
+		t.Errorf("Expected worktree to use %s, got %s", expected, worktreeJSON)
+	}
+}
+
+func TestGetOutputFilePathsWithoutRemote(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv(StoreDirEnvVar, "")
+
+	// Two repos without a remote that have the same base name.
+	repos := []string{filepath.Join(home, "one", "api"), filepath.Join(home, "two", "api")}
+	outputDirs := []string{}
+	for _, repo := range repos {
+		git(t, home, "init", "-q", repo)
+		writeFiles(t, repo, map[string]string{"settings.json": `{}`})
+		jsonOutputFile, _, err := GetOutputFilePaths(filepath.Join(repo, "settings.json"))
+		if err != nil {
+			t.Fatalf("Failed to get output file paths: %v", err)
+		}
+		outputDir := filepath.Dir(jsonOutputFile)
+		if info, err := os.Lstat(outputDir); err != nil || !info.IsDir() {
+			t.Errorf("Expected %s to be a directory (%v)", outputDir, err)
+		}
+		outputDirs = append(outputDirs, outputDir)
+	}
+	if outputDirs[0] == outputDirs[1] {
+		t.Errorf("Expected repos at different paths not to share %s", outputDirs[0])
+	}
+	for _, outputDir := range outputDirs {
+		if name := filepath.Base(outputDir); !strings.HasPrefix(name, "api-") {
+			t.Errorf("Expected the project name to start with the repo's name, got %s", name)
+		}
+	}
+	alias := filepath.Join(home, ".repo-config", "api")
+	if target, err := os.Readlink(alias); err != nil || target != filepath.Base(outputDirs[0]) {
+		t.Errorf("Expected the alias to point to the first repo, got %q (%v)", target, err)
+	}
+}
+
+func TestEnsureProjectDirReplacesAlias(t *testing.T) {
+	storeDir := t.TempDir()
+	writeFiles(t, filepath.Join(storeDir, "github.com_contoso_api"), map[string]string{".settings-values.json": `{"key": "value"}`})
+	if err := os.Symlink("github.com_contoso_api", filepath.Join(storeDir, "api")); err != nil {
+		t.Fatalf("Failed to create alias: %v", err)
+	}
+
+	outputDir, err := ensureProjectDir(storeDir, "api", "api")
+	if err != nil {
+		t.Fatalf("Failed to create the project directory: %v", err)
+	}
+	if info, err := os.Lstat(outputDir); err != nil || !info.IsDir() {
+		t.Fatalf("Expected the alias to be replaced by a directory (%v)", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, ".settings-values.json")); !os.IsNotExist(err) {
+		t.Error("Expected the other project's values not to be shared")
+	}
+	if _, err := os.Stat(filepath.Join(storeDir, "github.com_contoso_api", ".settings-values.json")); err != nil {
+		t.Errorf("Expected the other project's values to be kept: %v", err)
+	}
+}
@@ -0,0 +1,300 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Schemes of an item's source field, which says where its value comes from:
+//
+//	"source": "store"                     typed in and kept in the values file (the default)
+//	"source": "env:AZURE_LOCATION"        an environment variable
+//	"source": "file:~/.secrets/db"        the content of a file, relative to the input file
+//	"source": "cmd:az account show ..."   the output of a command, run in the repo root
+//	"source": "vault:secret/data/app#pw"  a field of a Vault secret (see SourceVault)
+//	"source": "keyvault://vault/secret"   an Azure Key Vault secret (see SourceKeyVault)
+//	"source": "plugin:name:ref"           whatever a provider plugin says (see SourcePlugin)
+//
+// Values from anywhere but the store are resolved again on every collect, before
+// values are generated or prompted for, and saved with the others.
+const (
+	SourceStore = "store"
+	SourceEnv   = "env"
+	SourceFile  = "file"
+	SourceCmd   = "cmd"
+)
+
+// Provider resolves the values of items whose source names its scheme.
+type Provider interface {
+	// Resolve returns the value of the item key, found by the reference part of
+	// its source.
+	Resolve(ctx context.Context, key string, item ItemConfig) (string, error)
+}
+
+// parseSource splits source into its scheme and reference.  Items without a source
+// are kept in the store.
+func parseSource(source string) (scheme, ref string) {
+	if source == "" {
+		return SourceStore, ""
+	}
+	scheme, ref, _ = strings.Cut(source, ":")
+	return scheme, ref
+}
+
+// applySecretBackend turns the sources of secret items that are just a reference,
+// without a scheme, into sources of the secretBackend setting: with vault,
+// "source": "secret/data/app#pw" reads vault:secret/data/app#pw, and with keyvault,
+// "source": "contoso-dev/db-password" reads keyvault://contoso-dev/db-password.
+// Secret items without a source are kept in the store like any other.
+func applySecretBackend(configMap map[string]ItemConfig) error {
+	for key, item := range configMap {
+		if !item.Secret || item.Source == "" || item.Source == SourceStore || strings.Contains(item.Source, ":") {
+			continue
+		}
+		switch activeSettings.SecretBackend {
+		case SourceVault:
+			item.Source = SourceVault + ":" + item.Source
+		case SourceKeyVault:
+			item.Source = SourceKeyVault + "://" + strings.TrimPrefix(item.Source, "//")
+		default:
+			return fmt.Errorf("the source '%s' of the secret '%s' has no scheme and the secretBackend setting is %s: add a scheme, e.g. vault:%s, or set secretBackend to vault or keyvault",
+				item.Source, key, activeSettings.SecretBackend, item.Source)
+		}
+		configMap[key] = item
+	}
+	return nil
+}
+
+// newProviders returns the providers for one run over inputFile, by scheme; runner
+// runs its cmd sources.  New backends are added here; providers may cache what they
+// fetch for the run.
+func newProviders(inputFile string, runner scriptRunner) map[string]Provider {
+	dir := filepath.Dir(inputFile)
+	if absDir, err := filepath.Abs(dir); err == nil {
+		dir = absDir
+	}
+	return map[string]Provider{
+		SourceStore:    storeProvider{},
+		SourceEnv:      envProvider{},
+		SourceFile:     fileProvider{dir: dir},
+		SourceCmd:      cmdProvider{runner: runner},
+		SourceVault:    newVaultProvider(),
+		SourceKeyVault: newKeyVaultProvider(),
+		SourcePlugin:   newPluginProvider(),
+	}
+}
+
+// SourceError is a setting whose value its source failed to resolve.  It is reported
+// in the errors of the status output.
+type SourceError struct {
+	Key    string `json:"key"`
+	Source string `json:"source"`
+	// Code classifies the failure when the provider says what it was, e.g. a
+	// plugin's error code.
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message"`
+	// ExitCode and Stderr are set when a script failed: the code it exited with,
+	// and the end of what it wrote to stderr.
+	ExitCode *int   `json:"exit_code,omitempty"`
+	Stderr   string `json:"stderr,omitempty"`
+}
+
+func (e SourceError) Error() string {
+	return fmt.Sprintf("failed to resolve '%s' from '%s': %s", e.Key, e.Source, e.Message)
+}
+
+// fromScript reports whether the failure is that of a script, a shellscript or a cmd
+// source, whose setting collecting asks for instead.
+func (e SourceError) fromScript() bool {
+	scheme, _ := parseSource(e.Source)
+	return scheme == shellScriptSource || scheme == SourceCmd
+}
+
+// SourceErrors are the settings that failed to resolve in one collection.
+type SourceErrors []SourceError
+
+func (errs SourceErrors) Error() string {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// codedError is an error that says what kind of failure it is.
+type codedError interface {
+	error
+	errorCode() string
+}
+
+// providerError is a failure of a built-in provider that says what kind it was, with
+// the codes plugins use for the same failures: invalid_source, permission_denied and
+// not_found.
+type providerError struct {
+	code    string
+	message string
+}
+
+func (e *providerError) Error() string {
+	return e.message
+}
+
+func (e *providerError) errorCode() string {
+	return e.code
+}
+
+// newSourceError returns the SourceError for the failure err resolving key.
+func newSourceError(key string, item ItemConfig, err error) SourceError {
+	sourceErr := SourceError{Key: key, Source: item.Source, Message: err.Error()}
+	var coded codedError
+	if errors.As(err, &coded) {
+		sourceErr.Code = coded.errorCode()
+	}
+	var scriptErr *scriptError
+	if errors.As(err, &scriptErr) {
+		if scriptErr.exitCode >= 0 {
+			sourceErr.ExitCode = &scriptErr.exitCode
+		}
+		sourceErr.Stderr = scriptErr.stderr
+	}
+	return sourceErr
+}
+
+// resolveValues resolves the values of the active items of configMap in one pass, in
+// input file order: through the provider of their source, and by running the
+// shellscript of those that have no value yet with runner.  when expressions, cmd
+// sources and shellscripts all see the values resolved before them.  It returns the
+// keys of the items that were set.  Every setting is tried; those that fail keep the
+// value they had and are returned as SourceErrors.
+func resolveValues(ctx context.Context, configMap map[string]ItemConfig, providers map[string]Provider, runner scriptRunner) ([]string, error) {
+	resolved := []string{}
+	var failed SourceErrors
+	for _, key := range orderedKeys(configMap) {
+		item := configMap[key]
+		if !isActive(configMap, item) {
+			continue
+		}
+		scheme, _ := parseSource(item.Source)
+		if scheme != SourceStore {
+			provider, exists := providers[scheme]
+			if !exists {
+				failed = append(failed, SourceError{Key: key, Source: item.Source, Code: "unknown_source",
+					Message: fmt.Sprintf("unknown source: expected one of %s", strings.Join(providerSchemes(providers), ", "))})
+				continue
+			}
+			value, err := provider.Resolve(ctx, key, item)
+			if err != nil {
+				failed = append(failed, newSourceError(key, item, err))
+				continue
+			}
+			item.Default = value
+			configMap[key] = item
+			resolved = append(resolved, key)
+		}
+		if !needsShellScript(item) {
+			continue
+		}
+		value, err := runner.run(ctx, key, item, shellScriptSource, item.ShellScript)
+		if err != nil {
+			sourceErr := newSourceError(key, item, err)
+			sourceErr.Source = shellScriptSource
+			failed = append(failed, sourceErr)
+			continue
+		}
+		item.Default = value
+		configMap[key] = item
+		if scheme == SourceStore {
+			resolved = append(resolved, key)
+		}
+	}
+	if len(failed) > 0 {
+		return resolved, failed
+	}
+	return resolved, nil
+}
+
+// providerSchemes returns the schemes of providers, sorted.
+func providerSchemes(providers map[string]Provider) []string {
+	schemes := make([]string, 0, len(providers))
+	for scheme := range providers {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// storeProvider resolves values that are typed in and kept in the values file, which
+// have been loaded into the item already.
+type storeProvider struct{}
+
+func (storeProvider) Resolve(ctx context.Context, key string, item ItemConfig) (string, error) {
+	return item.Default, nil
+}
+
+// envProvider resolves "env:NAME" from the environment variable NAME.
+type envProvider struct{}
+
+func (envProvider) Resolve(ctx context.Context, key string, item ItemConfig) (string, error) {
+	_, name := parseSource(item.Source)
+	if err := checkEnvName(name); err != nil {
+		return "", err
+	}
+	value, exists := os.LookupEnv(name)
+	if !exists {
+		return "", fmt.Errorf("environment variable '%s' is not set", name)
+	}
+	return value, nil
+}
+
+// fileProvider resolves "file:path" from the content of the file, without its final
+// line break.  Relative paths are relative to dir, the directory of the input file,
+// and ~/ is the home directory.
+type fileProvider struct {
+	dir string
+}
+
+func (p fileProvider) Resolve(ctx context.Context, key string, item ItemConfig) (string, error) {
+	_, path := parseSource(item.Source)
+	if path == "" {
+		return "", errors.New("expected a path after 'file:'")
+	}
+	if rest, found := strings.CutPrefix(path, "~/"); found {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get home directory: %v", err)
+		}
+		path = filepath.Join(homeDir, rest)
+	} else if !filepath.IsAbs(path) {
+		path = filepath.Join(p.dir, path)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return trimLineBreak(string(content)), nil
+}
+
+// cmdProvider resolves "cmd:command" from the output of running the command (see
+// scriptRunner).
+type cmdProvider struct {
+	runner scriptRunner
+}
+
+func (p cmdProvider) Resolve(ctx context.Context, key string, item ItemConfig) (string, error) {
+	_, command := parseSource(item.Source)
+	if command == "" {
+		return "", errors.New("expected a command after 'cmd:'")
+	}
+	return p.runner.run(ctx, key, item, item.Source, command)
+}
+
+// trimLineBreak removes the line break files and command output usually end with.
+func trimLineBreak(value string) string {
+	value = strings.TrimSuffix(value, "\n")
+	return strings.TrimSuffix(value, "\r")
+}
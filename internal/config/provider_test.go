@@ -0,0 +1,124 @@
+package config
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// trustAll approves every script, for tests that run scripts without a trust store.
+func trustAll(inputScript) bool {
+	return true
+}
+
+func TestResolveValues(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"secrets/db": "s3cret\n"})
+	t.Setenv("TEST_LOCATION", "eastus")
+	configMap := map[string]ItemConfig{
+		"location": {Source: "env:TEST_LOCATION", Order: 1},
+		"password": {Source: "file:secrets/db", Order: 2},
+		"greeting": {Source: "cmd:printf 'hi %s' \"$TEST_LOCATION\"", Order: 3},
+		"typed":    {Default: "kept", Order: 4},
+		"skipped":  {Source: "env:TEST_UNSET", When: "typed == other", Order: 5},
+	}
+
+	runner := scriptRunner{dir: dir, configMap: configMap, trusted: trustAll}
+	resolved, err := resolveValues(context.Background(), configMap, newProviders(filepath.Join(dir, "repo-config.json"), runner), runner)
+	if err != nil {
+		t.Fatalf("Failed to resolve sources: %v", err)
+	}
+	if strings.Join(resolved, ",") != "location,password,greeting" {
+		t.Errorf("Unexpected resolved keys %v", resolved)
+	}
+	expected := map[string]string{"location": "eastus", "password": "s3cret", "greeting": "hi eastus", "typed": "kept", "skipped": ""}
+	for key, value := range expected {
+		if configMap[key].Default != value {
+			t.Errorf("Expected %s to be %q, got %q", key, value, configMap[key].Default)
+		}
+	}
+}
+
+func TestResolveValuesErrors(t *testing.T) {
+	dir := t.TempDir()
+	runner := scriptRunner{dir: dir, trusted: trustAll}
+	providers := newProviders(filepath.Join(dir, "repo-config.json"), runner)
+	for source, expected := range map[string]string{
+		"env:TEST_UNSET":            "environment variable 'TEST_UNSET' is not set",
+		"file:missing":              "no such file",
+		"cmd:echo oops >&2; exit 3": "exit status 3: oops",
+		"nope:secret":               "failed to resolve 'key' from 'nope:secret': unknown source",
+	} {
+		configMap := map[string]ItemConfig{"key": {Source: source}}
+		_, err := resolveValues(context.Background(), configMap, providers, runner)
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected an error containing %q for %s, got %v", expected, source, err)
+		}
+	}
+}
+
+func TestApplySecretBackend(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"settings.yaml": `
+dbPassword:
+  secret: true
+  source: secret/data/app#pw
+apiKey:
+  secret: true
+  source: env:API_KEY
+typed:
+  secret: true
+plain:
+  source: file:plain.txt
+`})
+	inputFile := filepath.Join(dir, "settings.yaml")
+	t.Cleanup(func() { UseSettings(defaultSettings()) })
+
+	// The store doesn't take references.
+	if _, err := loadConfigFile(inputFile); err == nil || !strings.Contains(err.Error(), "secretBackend setting is store") {
+		t.Errorf("Expected an error for a reference without a backend, got %v", err)
+	}
+
+	settings := defaultSettings()
+	settings.SecretBackend = SecretBackendVault
+	UseSettings(settings)
+	configMap, err := loadConfigFile(inputFile)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	expected := map[string]string{"dbPassword": "vault:secret/data/app#pw", "apiKey": "env:API_KEY", "typed": "", "plain": "file:plain.txt"}
+	for key, source := range expected {
+		if configMap[key].Source != source {
+			t.Errorf("Expected the source of %s to be %q, got %q", key, source, configMap[key].Source)
+		}
+	}
+
+	settings.SecretBackend = SecretBackendKeyVault
+	UseSettings(settings)
+	writeFiles(t, dir, map[string]string{"settings.yaml": "dbPassword:\n  secret: true\n  source: contoso-dev/db-password\n"})
+	if configMap, err = loadConfigFile(inputFile); err != nil || configMap["dbPassword"].Source != "keyvault://contoso-dev/db-password" {
+		t.Errorf("Expected a Key Vault source, got %q, %v", configMap["dbPassword"].Source, err)
+	}
+}
+
+func TestCollectConfigResolvesSources(t *testing.T) {
+	inputFile := newSessionTestRepo(t)
+	writeFiles(t, filepath.Dir(inputFile), map[string]string{filepath.Base(inputFile): `
+region:
+  default: westus
+  tempEnvironmentVariableName: REGION
+subscription:
+  source: env:TEST_SUBSCRIPTION
+  tempEnvironmentVariableName: SUBSCRIPTION
+`})
+	t.Setenv("TEST_SUBSCRIPTION", "1234")
+
+	// The new setting has a value, so silent mode doesn't prompt.
+	if err := CollectConfig(inputFile, true); err != nil {
+		t.Fatalf("Expected collect to succeed, got %v", err)
+	}
+	if values := readValues(t, inputFile); values["subscription"] != "1234" {
+		t.Errorf("Expected the resolved value to be saved, got %v", values)
+	}
+}
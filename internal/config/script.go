@@ -0,0 +1,250 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// shellScriptSource is what errors of an item's shellscript name as its source.
+const shellScriptSource = "shellscript"
+
+// Shells an item's shell field may choose for its scripts.  sh is the default.
+const (
+	scriptShellSh   = "sh"
+	scriptShellBash = "bash"
+	scriptShellPwsh = "pwsh"
+)
+
+// shellArgs are the arguments each shell runs a script given after them with.
+var shellArgs = map[string][]string{
+	scriptShellSh:   {"-c"},
+	scriptShellBash: {"-c"},
+	scriptShellPwsh: {"-NoProfile", "-NonInteractive", "-Command"},
+}
+
+// processWaitDelay is how long to wait for the output of a script or plugin after it
+// was killed for running too long, in case processes it started still hold it open.
+const processWaitDelay = time.Second
+
+// scriptSettingPrefix is put before the upper snake case name a setting without an
+// environment variable name has in the environment of scripts, so settings named e.g.
+// path or home don't replace PATH or HOME.
+const scriptSettingPrefix = "REPO_CONFIG_VALUE_"
+
+// maxScriptStderr is how much of the stderr of a failed script its error keeps.
+const maxScriptStderr = 1024
+
+// scriptDir returns the directory scripts of inputFile run in: the root of its repo,
+// or its directory outside of one.
+func scriptDir(inputFile string) string {
+	dir := filepath.Dir(inputFile)
+	if absDir, err := filepath.Abs(dir); err == nil {
+		dir = absDir
+	}
+	if root, err := getGitRoot(dir); err == nil {
+		return root
+	}
+	return dir
+}
+
+// scriptRunner runs the shellscripts and cmd sources of the items of one input file.
+// A script runs with the item's shell, sh by default, in its cwd, relative to dir,
+// the root of the repo, for its timeout or else the scriptTimeout setting.  Its
+// environment has the settings of configMap that have a value already, under their
+// environment variable name or else their name in upper snake case after
+// scriptSettingPrefix, and then the item's env on top.  Only scripts approved for the
+// repo (see trusted) run.
+type scriptRunner struct {
+	dir       string
+	configMap map[string]ItemConfig
+	trusted   func(inputScript) bool
+}
+
+// newScriptRunner returns the scriptRunner for the items of inputFile, which runs the
+// scripts approved for its repo in the trust store.
+func newScriptRunner(inputFile string, configMap map[string]ItemConfig) (scriptRunner, error) {
+	store, err := loadTrustStore()
+	if err != nil {
+		return scriptRunner{}, err
+	}
+	repo := trustRepo(inputFile)
+	return scriptRunner{dir: scriptDir(inputFile), configMap: configMap,
+		trusted: func(script inputScript) bool { return store.trusted(repo, script) }}, nil
+}
+
+// scriptError is a script that failed or ran too long.
+type scriptError struct {
+	code     string // "failed", "timeout" or "untrusted"
+	exitCode int    // -1 unless the script exited
+	stderr   string // the end of its stderr, truncated to maxScriptStderr
+	message  string
+}
+
+func (e *scriptError) Error() string {
+	return e.message
+}
+
+func (e *scriptError) errorCode() string {
+	return e.code
+}
+
+// run runs script, the shellscript or cmd source of the item key, and returns its
+// output without the final line break.  source is shellScriptSource or the cmd source.
+// Approving scripts before collecting sees values that aren't resolved yet, so run
+// checks again that the script was approved before running it.
+func (r scriptRunner) run(ctx context.Context, key string, item ItemConfig, source, script string) (string, error) {
+	if r.trusted == nil || !r.trusted(newInputScript(key, item, source, script)) {
+		return "", &scriptError{code: "untrusted", exitCode: -1, message: untrustedMessage}
+	}
+	shell := item.Shell
+	if shell == "" {
+		shell = scriptShellSh
+	}
+	args, exists := shellArgs[shell]
+	if !exists {
+		return "", fmt.Errorf("unknown shell '%s': expected %s, %s or %s", shell, scriptShellSh, scriptShellBash, scriptShellPwsh)
+	}
+	timeout := activeSettings.scriptTimeout()
+	if item.Timeout != "" {
+		var err error
+		if timeout, err = parseScriptTimeout(item.Timeout); err != nil {
+			return "", err
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, shell, append(args, script)...)
+	cmd.Dir = r.dir
+	if item.Cwd != "" {
+		cmd.Dir = item.Cwd
+		if !filepath.IsAbs(item.Cwd) {
+			cmd.Dir = filepath.Join(r.dir, item.Cwd)
+		}
+	}
+	cmd.Env = append(os.Environ(), r.environment(key)...)
+	for _, name := range sortedEnvNames(item.Env) {
+		cmd.Env = append(cmd.Env, name+"="+item.Env[name])
+	}
+	cmd.WaitDelay = processWaitDelay
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if ctx.Err() == context.DeadlineExceeded {
+		return "", &scriptError{code: "timeout", exitCode: -1, stderr: truncateStderr(stderr.String()),
+			message: fmt.Sprintf("command timed out after %s", timeout)}
+	} else if err != nil {
+		scriptErr := &scriptError{code: "failed", exitCode: -1, stderr: truncateStderr(stderr.String())}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			scriptErr.exitCode = exitErr.ExitCode()
+		}
+		scriptErr.message = fmt.Sprintf("command failed: %v", err)
+		if scriptErr.stderr != "" {
+			scriptErr.message += ": " + scriptErr.stderr
+		}
+		return "", scriptErr
+	}
+	return trimLineBreak(string(output)), nil
+}
+
+// environment returns the variables of the settings other than key that have a value,
+// in input file order.  Settings whose name is no valid variable name are left out.
+func (r scriptRunner) environment(key string) []string {
+	env := []string{}
+	for _, other := range orderedKeys(r.configMap) {
+		item := r.configMap[other]
+		if other == key || item.isMissing() || !isActive(r.configMap, item) {
+			continue
+		}
+		name := envVarName(other, item)
+		if name == "" {
+			name = scriptSettingPrefix + upperSnake(other)
+		}
+		if checkEnvName(name) == nil {
+			env = append(env, name+"="+item.Default)
+		}
+	}
+	return env
+}
+
+// truncateStderr returns the stderr of a failed script for its error: trimmed, and if
+// it is longer than maxScriptStderr, its end, which usually says what went wrong.
+func truncateStderr(stderr string) string {
+	stderr = strings.TrimSpace(stderr)
+	if len(stderr) <= maxScriptStderr {
+		return stderr
+	}
+	return "..." + strings.ToValidUTF8(stderr[len(stderr)-maxScriptStderr:], "")
+}
+
+// parseScriptTimeout parses the timeout field of an item.
+func parseScriptTimeout(timeout string) (time.Duration, error) {
+	duration, err := time.ParseDuration(timeout)
+	if err != nil || duration <= 0 {
+		return 0, fmt.Errorf("invalid timeout '%s': expected a positive duration such as 30s", timeout)
+	}
+	return duration, nil
+}
+
+// sortedEnvNames returns the names of env, sorted.
+func sortedEnvNames(env map[string]string) []string {
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// validateScriptOptions checks the timeout, cwd, shell and env fields of the items of
+// configMap, which only items that run a script may have.
+func validateScriptOptions(configMap map[string]ItemConfig) error {
+	for _, key := range orderedKeys(configMap) {
+		item := configMap[key]
+		if item.Timeout == "" && item.Cwd == "" && item.Shell == "" && len(item.Env) == 0 {
+			continue
+		}
+		if scheme, _ := parseSource(item.Source); item.ShellScript == "" && scheme != SourceCmd {
+			return fmt.Errorf("setting '%s': timeout, cwd, shell and env only apply to a shellscript or cmd source", key)
+		}
+		if item.Timeout != "" {
+			if _, err := parseScriptTimeout(item.Timeout); err != nil {
+				return fmt.Errorf("setting '%s': %v", key, err)
+			}
+		}
+		if _, exists := shellArgs[item.Shell]; item.Shell != "" && !exists {
+			return fmt.Errorf("setting '%s': unknown shell '%s': expected %s, %s or %s", key, item.Shell, scriptShellSh, scriptShellBash, scriptShellPwsh)
+		}
+		for _, name := range sortedEnvNames(item.Env) {
+			if err := checkEnvName(name); err != nil {
+				return fmt.Errorf("setting '%s': %v", key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// scriptKind names the kind of script of source, shellScriptSource or a cmd source,
+// for messages.
+func scriptKind(source string) string {
+	if source == shellScriptSource {
+		return shellScriptSource
+	}
+	return "command"
+}
+
+// needsShellScript reports whether item has a shellscript and no value yet, so that
+// collecting runs the script if the item is active.
+func needsShellScript(item ItemConfig) bool {
+	return item.ShellScript != "" && item.Default == "" && !item.EmptyIsValid
+}
@@ -0,0 +1,180 @@
+//go:build unix
+
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShellScriptOptions(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "tools"), 0755); err != nil {
+		t.Fatalf("Failed to create tools: %v", err)
+	}
+	configMap := map[string]ItemConfig{
+		"region":     {Default: "westus", Order: 0},
+		"dbPassword": {Default: "s3cret", TempEnvironmentVariableName: "DB_PASSWORD", Order: 1},
+		"greeting":   {ShellScript: `echo "$GREETING from $REPO_CONFIG_VALUE_REGION"`, Env: map[string]string{"GREETING": "hello"}, Order: 2},
+		"connection": {ShellScript: `echo "$REPO_CONFIG_VALUE_GREETING;$DB_PASSWORD"`, Order: 3},
+		"shell":      {ShellScript: `echo "${BASH_VERSION:+bash}"`, Shell: "bash", Order: 4},
+		"cwd":        {ShellScript: `basename "$PWD"`, Cwd: "tools", Order: 5},
+		"overridden": {ShellScript: `echo "$REPO_CONFIG_VALUE_REGION"`, Env: map[string]string{"REPO_CONFIG_VALUE_REGION": "eastus"}, Order: 6},
+		"path":       {Default: "nowhere", Order: 7},
+		"keptPath":   {ShellScript: `[ "$PATH" != nowhere ] && echo "$REPO_CONFIG_VALUE_PATH"`, Order: 8},
+	}
+	runner := scriptRunner{dir: dir, configMap: configMap, trusted: trustAll}
+	set, err := resolveValues(context.Background(), configMap, newProviders(filepath.Join(dir, "repo-config.json"), runner), runner)
+	if err != nil {
+		t.Fatalf("Expected every script to run, got %v", err)
+	}
+	if len(set) != 6 {
+		t.Errorf("Expected 6 settings to be set, got %v", set)
+	}
+	expected := map[string]string{
+		"greeting":   "hello from westus",
+		"connection": "hello from westus;s3cret",
+		"shell":      "bash",
+		"cwd":        "tools",
+		"overridden": "eastus",
+		"keptPath":   "nowhere",
+	}
+	for key, value := range expected {
+		if configMap[key].Default != value {
+			t.Errorf("Expected %s to be %q, got %q", key, value, configMap[key].Default)
+		}
+	}
+}
+
+func TestShellScriptFailures(t *testing.T) {
+	configMap := map[string]ItemConfig{
+		"failed":  {ShellScript: "echo first >&2; echo oops >&2; exit 3", Order: 0},
+		"noisy":   {ShellScript: "yes error | head -n 500 >&2; echo done >&2; exit 1", Order: 1},
+		"slow":    {ShellScript: "exec sleep 10", Timeout: "100ms", Order: 2},
+		"working": {ShellScript: "echo fine", Order: 3},
+	}
+	start := time.Now()
+	dir := t.TempDir()
+	runner := scriptRunner{dir: dir, configMap: configMap, trusted: trustAll}
+	set, err := resolveValues(context.Background(), configMap, newProviders(filepath.Join(dir, "repo-config.json"), runner), runner)
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("Expected the slow script to time out, took %s", time.Since(start))
+	}
+	failed, ok := err.(SourceErrors)
+	if !ok || len(failed) != 3 {
+		t.Fatalf("Expected three failed scripts, got %v", err)
+	}
+	if strings.Join(set, ",") != "working" || configMap["failed"].Default != "" {
+		t.Errorf("Expected only the working script to set its value, got %v", set)
+	}
+
+	if failed[0].Key != "failed" || failed[0].Source != shellScriptSource || failed[0].Code != "failed" ||
+		failed[0].ExitCode == nil || *failed[0].ExitCode != 3 || failed[0].Stderr != "first\noops" {
+		t.Errorf("Expected exit code 3 and the stderr of the failed script, got %+v", failed[0])
+	}
+	if !strings.HasPrefix(failed[1].Stderr, "...") || !strings.HasSuffix(failed[1].Stderr, "error\ndone") ||
+		len(failed[1].Stderr) != maxScriptStderr+3 {
+		t.Errorf("Expected the end of the stderr of the noisy script, got %d bytes: %q", len(failed[1].Stderr), failed[1].Stderr)
+	}
+	if failed[2].Code != "timeout" || failed[2].ExitCode != nil || !strings.Contains(failed[2].Message, "timed out after 100ms") {
+		t.Errorf("Expected the slow script to time out, got %+v", failed[2])
+	}
+}
+
+func TestCollectContinuesAfterFailedScript(t *testing.T) {
+	inputFile := newTrustTestRepo(t)
+	writeFiles(t, filepath.Dir(inputFile), map[string]string{filepath.Base(inputFile): `
+broken:
+  shellscript: "echo nope >&2; exit 4"
+account:
+  source: "cmd:exit 3"
+owner:
+  shellscript: "echo me"
+`})
+	if _, err := TrustScripts(inputFile); err != nil {
+		t.Fatalf("Failed to approve the scripts: %v", err)
+	}
+
+	s, err := newSession(inputFile)
+	if err != nil {
+		t.Fatalf("Failed to load %s: %v", inputFile, err)
+	}
+	defer s.close()
+	if err := s.collect(false, strings.NewReader("1\nfixed\n2\ncontoso\ns\nc\n")); err != nil {
+		t.Fatalf("Expected to be asked for the values of the failed scripts, got %v", err)
+	}
+	if len(s.scriptErrors) != 2 || s.scriptErrors[0].Key != "broken" || s.scriptErrors[0].Stderr != "nope" ||
+		s.scriptErrors[1].Key != "account" || s.scriptErrors[1].Code != "failed" {
+		t.Errorf("Expected the failed scripts to be reported, got %v", s.scriptErrors)
+	}
+	if values := readValues(t, inputFile); values["broken"] != "fixed" || values["account"] != "contoso" || values["owner"] != "me" {
+		t.Errorf("Expected the prompt to fill in the failed values, got %v", values)
+	}
+}
+
+func TestCmdSourceSeesResolvedSettings(t *testing.T) {
+	t.Setenv("TEST_LOCATION", "westus")
+	configMap := map[string]ItemConfig{
+		"location": {Source: "env:TEST_LOCATION", Order: 0},
+		"group":    {Source: `cmd:echo "rg-$REPO_CONFIG_VALUE_LOCATION"`, Order: 1},
+		"failing":  {Source: "cmd:echo denied >&2; exit 2", Order: 2},
+		"account":  {ShellScript: "echo contoso", Order: 3},
+		"conn":     {Source: `cmd:echo "acct=$REPO_CONFIG_VALUE_ACCOUNT"`, Order: 4},
+	}
+	dir := t.TempDir()
+	runner := scriptRunner{dir: dir, configMap: configMap, trusted: trustAll}
+	resolved, err := resolveValues(context.Background(), configMap, newProviders(filepath.Join(dir, "repo-config.json"), runner), runner)
+	failed, ok := err.(SourceErrors)
+	if !ok || len(failed) != 1 || failed[0].ExitCode == nil || *failed[0].ExitCode != 2 || failed[0].Stderr != "denied" {
+		t.Fatalf("Expected the failing command's exit code and stderr, got %v", err)
+	}
+	if strings.Join(resolved, ",") != "location,group,account,conn" {
+		t.Errorf("Expected the other settings to be resolved in order, got %v", resolved)
+	}
+	if configMap["group"].Default != "rg-westus" {
+		t.Errorf("Expected the command to see the location, got %q", configMap["group"].Default)
+	}
+	if configMap["conn"].Default != "acct=contoso" {
+		t.Errorf("Expected the command to see the value of the shellscript before it, got %q", configMap["conn"].Default)
+	}
+}
+
+func TestValidateScriptOptions(t *testing.T) {
+	for expected, item := range map[string]ItemConfig{
+		"only apply to a shellscript or cmd source": {Default: "x", Shell: "bash"},
+		"invalid timeout '10'":                      {ShellScript: "true", Timeout: "10"},
+		"unknown shell 'fish'":                      {Source: "cmd:true", Shell: "fish"},
+		"invalid environment variable name 'A-B'":   {ShellScript: "true", Env: map[string]string{"A-B": "x"}},
+	} {
+		err := validateScriptOptions(map[string]ItemConfig{"key": item})
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected an error containing %q, got %v", expected, err)
+		}
+	}
+	valid := ItemConfig{ShellScript: "true", Timeout: "2m", Cwd: "tools", Shell: "pwsh", Env: map[string]string{"A": "b"}}
+	if err := validateScriptOptions(map[string]ItemConfig{"key": valid}); err != nil {
+		t.Errorf("Expected valid options, got %v", err)
+	}
+}
+
+func TestScriptHashCoversOptions(t *testing.T) {
+	item := ItemConfig{ShellScript: "make token"}
+	plain := scriptHash(newInputScript("key", item, shellScriptSource, item.ShellScript))
+	if plain != scriptHash(inputScript{script: "make token"}) {
+		t.Error("Expected a script without options to hash like the script alone")
+	}
+	item.Env = map[string]string{"TARGET": "prod"}
+	if scriptHash(newInputScript("key", item, shellScriptSource, item.ShellScript)) == plain {
+		t.Error("Expected changing the env to change the hash")
+	}
+	item.Timeout = "1m"
+	withTimeout := scriptHash(newInputScript("key", item, shellScriptSource, item.ShellScript))
+	item.Timeout = ""
+	if withTimeout != scriptHash(newInputScript("key", item, shellScriptSource, item.ShellScript)) {
+		t.Error("Expected the timeout not to change the hash")
+	}
+}
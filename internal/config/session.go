@@ -0,0 +1,226 @@
+package config
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// session holds what one run knows about an input file: the parsed input, its output
+// files and the values collected before.  Building it reads the input, resolves the
+// project and locates the output files once, so nothing below repeats that work.
+// It holds the project's lock until it is closed.
+type session struct {
+	inputFile      string
+	jsonOutputFile string
+	envOutputFile  string
+	lock           *projectLock
+	inputModTime   time.Time
+	// outputModTime is zero when the values haven't been collected yet.
+	outputModTime time.Time
+	// configMap holds the settings of the input file, with their existing values.
+	configMap      map[string]ItemConfig
+	existingValues map[string]string
+	// scriptErrors are the shellscripts and cmd sources that failed while
+	// collecting.  Their settings are asked for instead.
+	scriptErrors SourceErrors
+}
+
+// newSession loads inputFile and the values collected for it before, after locking
+// the project so no other process changes them until the session is closed.
+func newSession(inputFile string) (*session, error) {
+	// Check if the input JSON file exists.
+	inputInfo, err := os.Stat(inputFile)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("JSON file '%s' not found", inputFile)
+	} else if err != nil {
+		return nil, err
+	}
+
+	// Load the input JSON file.
+	configMap, err := loadConfigFile(inputFile)
+	if err != nil {
+		return nil, err
+	}
+
+	// Determine the output file paths.
+	files, err := locateOutputFiles(inputFile)
+	if err != nil {
+		return nil, err
+	}
+	outputDir, err := ensureProjectDir(files.storeDir, files.project.name, files.project.alias)
+	if err != nil {
+		return nil, err
+	}
+	jsonOutputFile, envOutputFile := files.json, files.env
+
+	lock, err := lockProject(outputDir, activeSettings.lockTimeout())
+	if err != nil {
+		return nil, err
+	}
+	s := &session{
+		inputFile:      inputFile,
+		jsonOutputFile: jsonOutputFile,
+		envOutputFile:  envOutputFile,
+		lock:           lock,
+		inputModTime:   inputInfo.ModTime(),
+		configMap:      configMap,
+	}
+	if err := migrateLegacyOutputFiles(files); err != nil {
+		s.close()
+		return nil, err
+	}
+	if outputInfo, err := os.Stat(jsonOutputFile); err == nil {
+		s.outputModTime = outputInfo.ModTime()
+	} else if !os.IsNotExist(err) {
+		s.close()
+		return nil, err
+	}
+
+	// Load existing values from the output JSON file if it exists.
+	s.existingValues, err = loadExistingValues(configMap, jsonOutputFile)
+	if err != nil {
+		s.close()
+		return nil, err
+	}
+
+	// Update configMap with existing values
+	updateConfigMapWithExistingValues(configMap, s.existingValues)
+	return s, nil
+}
+
+// loadCollectedValues loads inputFile with the values collected for it, for commands
+// that only read them.  It doesn't lock the project, as values files are replaced
+// rather than rewritten in place, and it creates and migrates nothing, so it can run
+// while another process is collecting.
+func loadCollectedValues(inputFile string) (map[string]ItemConfig, error) {
+	configMap, err := loadConfigFile(inputFile)
+	if err != nil {
+		return nil, err
+	}
+	outputs, err := locateOutputFiles(inputFile)
+	if err != nil {
+		return nil, err
+	}
+	existingValues, err := loadExistingValues(configMap, outputs.json)
+	if err != nil {
+		return nil, err
+	}
+	updateConfigMapWithExistingValues(configMap, existingValues)
+	return configMap, nil
+}
+
+// close releases the project's lock.
+func (s *session) close() {
+	s.lock.unlock()
+}
+
+// collect fills in the values of the session's settings, prompting on inputReader
+// unless silent mode finds nothing to ask.
+func (s *session) collect(silent bool, inputReader io.Reader) error {
+	// Approving scripts and prompting share one reader, so neither loses what the
+	// other buffered.
+	reader := bufio.NewReader(inputReader)
+
+	// Scripts come from a checked-in file; nothing runs until the user approved it.
+	// Silent mode can't ask, so it only runs the scripts approved before.
+	if !silent {
+		if err := approveScripts(s.inputFile, s.configMap, reader); err != nil {
+			return err
+		}
+	}
+
+	// Resolve values that live elsewhere and run shellscripts first, so generating and
+	// prompting see them.  They need no user input either.
+	ctx := context.Background()
+	runner, err := newScriptRunner(s.inputFile, s.configMap)
+	if err != nil {
+		return err
+	}
+	resolved, err := resolveValues(ctx, s.configMap, newProviders(s.inputFile, runner), runner)
+	var failed SourceErrors
+	if !errors.As(err, &failed) && err != nil {
+		return err
+	}
+	for _, key := range resolved {
+		s.existingValues[key] = s.configMap[key].Default
+	}
+
+	// The settings of failed scripts are asked for instead; any other failure stops,
+	// as do unapproved scripts in silent mode, where nobody was asked about them.
+	var sourceErrors SourceErrors
+	for _, sourceErr := range failed {
+		if !sourceErr.fromScript() || (silent && sourceErr.Code == "untrusted") {
+			sourceErrors = append(sourceErrors, sourceErr)
+			continue
+		}
+		s.scriptErrors = append(s.scriptErrors, sourceErr)
+		if sourceErr.Code == "untrusted" {
+			fmt.Fprintf(os.Stderr, "The %s of '%s' was not approved, so it didn't run.\n", scriptKind(sourceErr.Source), sourceErr.Key)
+		} else {
+			fmt.Fprintf(os.Stderr, "The %s of '%s' failed: %s\n", scriptKind(sourceErr.Source), sourceErr.Key, sourceErr.Message)
+		}
+	}
+	if len(sourceErrors) > 0 {
+		return sourceErrors
+	}
+
+	// Generate values that have never been set.  Generated values need no user
+	// input, so they don't count as new settings below.
+	generated, err := generateMissingValues(s.configMap)
+	if err != nil {
+		return err
+	}
+	for _, key := range generated {
+		s.existingValues[key] = s.configMap[key].Default
+	}
+
+	// Not in silent mode, proceed interactively.
+	if !silent {
+		return s.prompt(reader)
+	}
+
+	// Check if input JSON is newer than output JSON or output doesn't exist.
+	outputFileExists := !s.outputModTime.IsZero()
+	if !outputFileExists || s.inputModTime.After(s.outputModTime) {
+		// Check for new or deleted settings.
+		if compareConfigs(s.configMap, s.existingValues) {
+			// New settings found or settings deleted, proceed interactively.
+			return s.promptUnlessWaited("Configuration changes detected.", reader)
+		}
+	} else if missingValues := checkForMissingValues(s.configMap); len(missingValues) > 0 {
+		// Input JSON is older or same age, but some settings have no value.
+		return s.promptUnlessWaited("Missing values detected.", reader)
+	}
+
+	// All values present, save and return.  we need to save in case a setting has
+	// been deleted; when nothing changed, saving leaves the files alone.
+	return s.save()
+}
+
+// prompt lets the user update the session's settings interactively.
+func (s *session) prompt(inputReader io.Reader) error {
+	return promptForValues(s.configMap, inputReader, s.save)
+}
+
+// promptUnlessWaited prompts for the reason silent mode found, unless it waited for
+// another process to collect the project's values.  That process prompted already,
+// and the values it saved were loaded after it finished; if they are still
+// incomplete, the user chose not to finish them, so they aren't asked again in
+// every terminal.
+func (s *session) promptUnlessWaited(reason string, inputReader io.Reader) error {
+	if s.lock.waited {
+		return fmt.Errorf("values for '%s' are incomplete after another repo-config process collected them; run collect again to finish", s.inputFile)
+	}
+	fmt.Fprintln(os.Stderr, reason+" Proceeding interactively.")
+	return s.prompt(inputReader)
+}
+
+// save writes the session's values to its output files.
+func (s *session) save() error {
+	return saveConfigTo(s.jsonOutputFile, s.envOutputFile, s.configMap)
+}
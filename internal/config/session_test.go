@@ -0,0 +1,220 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newSessionTestRepo lays out a repository with a remote and an input file whose
+// values have all been collected, with HOME pointing at a temporary directory.  It
+// returns the path of the input file.
+func newSessionTestRepo(tb testing.TB) string {
+	tb.Helper()
+	return newSessionTestRepoWithInput(tb, "settings.yaml")
+}
+
+// newSessionTestRepoWithInput is newSessionTestRepo with the input file named inputName.
+func newSessionTestRepoWithInput(tb testing.TB, inputName string) string {
+	tb.Helper()
+	dir, err := os.MkdirTemp("", "config_test")
+	if err != nil {
+		tb.Fatalf("Failed to create temp dir: %v", err)
+	}
+	tb.Cleanup(func() { os.RemoveAll(dir) })
+
+	originalHome := os.Getenv("HOME")
+	os.Setenv("HOME", filepath.Join(dir, "home"))
+	tb.Cleanup(func() { os.Setenv("HOME", originalHome) })
+
+	writeFiles(tb, dir, map[string]string{
+		"repo/.git/config": "[remote \"origin\"]\n\turl = git@github.com:contoso/api.git\n",
+		"repo/api/" + inputName: `
+region:
+  description: Azure region
+  default: westus
+  tempEnvironmentVariableName: REGION
+dbPassword:
+  description: Database password
+  generate: {kind: password}
+  tempEnvironmentVariableName: DB_PASSWORD
+cacheName:
+  description: Cache name
+  default: cache
+  when: region == westus
+`,
+	})
+	inputFile := filepath.Join(dir, "repo", "api", inputName)
+
+	configMap, err := loadConfigFile(inputFile)
+	if err != nil {
+		tb.Fatalf("Failed to load config: %v", err)
+	}
+	if _, err := generateMissingValues(configMap); err != nil {
+		tb.Fatalf("Failed to generate values: %v", err)
+	}
+	saveTestValues(tb, inputFile, configMap)
+	return inputFile
+}
+
+// saveTestValues saves configMap as the values of inputFile through a session, so
+// tests write under the project's lock as collect does.
+func saveTestValues(tb testing.TB, inputFile string, configMap map[string]ItemConfig) {
+	tb.Helper()
+	s, err := newSession(inputFile)
+	if err != nil {
+		tb.Fatalf("Failed to open session: %v", err)
+	}
+	defer s.close()
+	s.configMap = configMap
+	if err := s.save(); err != nil {
+		tb.Fatalf("Failed to save config: %v", err)
+	}
+}
+
+func TestCollectConfigSilentLeavesUnchangedFilesAlone(t *testing.T) {
+	inputFile := newSessionTestRepo(t)
+	status := CollectConfigStatus(inputFile, true)
+	if status.Status != StatusOK {
+		t.Fatalf("Expected collect to succeed, got %s", status.Message)
+	}
+	if !strings.HasSuffix(status.JSONFile, filepath.Join("github.com_contoso_api", ".api__settings-values.json")) {
+		t.Errorf("Unexpected JSON output file %s", status.JSONFile)
+	}
+
+	// Age the files, keeping the input oldest, so a rewrite would be visible.
+	past := time.Now().Add(-time.Hour)
+	for _, path := range []string{status.JSONFile, status.EnvFile} {
+		if err := os.Chtimes(path, past, past); err != nil {
+			t.Fatalf("Failed to change modification time of %s: %v", path, err)
+		}
+	}
+	if err := os.Chtimes(inputFile, past.Add(-time.Minute), past.Add(-time.Minute)); err != nil {
+		t.Fatalf("Failed to change modification time of %s: %v", inputFile, err)
+	}
+
+	if err := CollectConfig(inputFile, true); err != nil {
+		t.Fatalf("Expected collect to succeed, got %v", err)
+	}
+	for _, path := range []string{status.JSONFile, status.EnvFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("Failed to stat %s: %v", path, err)
+		}
+		if !info.ModTime().Equal(past) {
+			t.Errorf("Expected %s not to be rewritten", path)
+		}
+	}
+}
+
+func TestCollectConfigKeepsValuesPrivate(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows has no permission bits for others")
+	}
+	inputFile := newSessionTestRepo(t)
+	status := CollectConfigStatus(inputFile, true)
+	if status.Status != StatusOK {
+		t.Fatalf("Expected collect to succeed, got %s", status.Message)
+	}
+	checkModes := func() {
+		t.Helper()
+		for _, path := range []string{status.JSONFile, status.EnvFile} {
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatalf("Failed to stat %s: %v", path, err)
+			}
+			if info.Mode().Perm() != 0600 {
+				t.Errorf("Expected %s to be readable by its owner only, got %v", path, info.Mode())
+			}
+		}
+	}
+	checkModes()
+
+	// Files older versions left readable by everyone are fixed even when the values
+	// are up to date.
+	for _, path := range []string{status.JSONFile, status.EnvFile} {
+		if err := os.Chmod(path, 0644); err != nil {
+			t.Fatalf("Failed to change mode of %s: %v", path, err)
+		}
+	}
+	if err := CollectConfig(inputFile, true); err != nil {
+		t.Fatalf("Expected collect to succeed, got %v", err)
+	}
+	checkModes()
+}
+
+func TestCollectConfigSilentPromptsForMissingValues(t *testing.T) {
+	inputFile := newSessionTestRepo(t)
+	jsonOutputFile, _, err := GetOutputFilePaths(inputFile)
+	if err != nil {
+		t.Fatalf("Failed to get output file paths: %v", err)
+	}
+
+	// The values are newer than the input, but one has been lost.
+	if err := os.WriteFile(jsonOutputFile, []byte(`{"region": ""}`), 0644); err != nil {
+		t.Fatalf("Failed to write values: %v", err)
+	}
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(inputFile, past, past); err != nil {
+		t.Fatalf("Failed to change modification time: %v", err)
+	}
+
+	s, err := newSession(inputFile)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	if s.outputModTime.IsZero() {
+		t.Fatal("Expected the session to know the values exist")
+	}
+	if err := s.collect(true, strings.NewReader("")); err == nil || !strings.Contains(err.Error(), "failed to read input") {
+		t.Errorf("Expected silent collect to prompt for the missing value, got %v", err)
+	}
+}
+
+// The benchmarks measure the cost of collecting when nothing has changed, which is
+// what runs every time a shell starts.
+
+func BenchmarkCollectConfigSilentUnchanged(b *testing.B) {
+	inputFile := newSessionTestRepo(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := CollectConfig(inputFile, true); err != nil {
+			b.Fatalf("Failed to collect: %v", err)
+		}
+	}
+}
+
+func BenchmarkNewSession(b *testing.B) {
+	inputFile := newSessionTestRepo(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s, err := newSession(inputFile)
+		if err != nil {
+			b.Fatalf("Failed to create session: %v", err)
+		}
+		s.close()
+	}
+}
+
+func BenchmarkLoadConfigFile(b *testing.B) {
+	inputFile := newSessionTestRepo(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := loadConfigFile(inputFile); err != nil {
+			b.Fatalf("Failed to load config: %v", err)
+		}
+	}
+}
+
+func BenchmarkGetOutputFilePaths(b *testing.B) {
+	inputFile := newSessionTestRepo(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := GetOutputFilePaths(inputFile); err != nil {
+			b.Fatalf("Failed to get output file paths: %v", err)
+		}
+	}
+}
@@ -0,0 +1,434 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Settings are the user-wide preferences of the tool.  They are read from the
+// settings file (see SettingsFile), overridden by environment variables, which are in
+// turn overridden by command line flags; see LoadSettings.
+type Settings struct {
+	// StoreDir is the directory values are stored in; see StoreDir.
+	StoreDir string `json:"storeDir" yaml:"storeDir"`
+	// OutputFormats are the output files written for each input file: json, which
+	// holds the values and is always written, and env.
+	OutputFormats []string `json:"outputFormats" yaml:"outputFormats"`
+	// EnvNaming derives the environment variable of requiredAsEnv items that don't
+	// name one with tempEnvironmentVariableName; see envNamings.
+	EnvNaming string `json:"envNaming" yaml:"envNaming"`
+	// SecretBackend is where the sources of secret items that are just a reference
+	// are looked up: store, which doesn't take references, vault or keyvault; see
+	// applySecretBackend.
+	SecretBackend string `json:"secretBackend" yaml:"secretBackend"`
+	// PromptStyle is how the interactive prompt lists settings: table or plain.
+	PromptStyle string `json:"promptStyle" yaml:"promptStyle"`
+	// ScriptTimeout is how long a shellscript, cmd source or plugin may run, e.g. 30s,
+	// unless the item sets a timeout.
+	ScriptTimeout string `json:"scriptTimeout" yaml:"scriptTimeout"`
+	// LockTimeout is how long to wait for another process that is collecting the
+	// same project's values, e.g. 2m.
+	LockTimeout string `json:"lockTimeout" yaml:"lockTimeout"`
+}
+
+// Values of the enumerated settings.
+const (
+	OutputFormatJSON = "json"
+	OutputFormatEnv  = "env"
+
+	EnvNamingNone       = "none"
+	EnvNamingUpperSnake = "upper-snake"
+	EnvNamingKey        = "key"
+
+	SecretBackendStore    = SourceStore
+	SecretBackendVault    = SourceVault
+	SecretBackendKeyVault = SourceKeyVault
+
+	PromptStyleTable = "table"
+	PromptStylePlain = "plain"
+)
+
+var (
+	outputFormats  = []string{OutputFormatJSON, OutputFormatEnv}
+	envNamings     = []string{EnvNamingNone, EnvNamingUpperSnake, EnvNamingKey}
+	secretBackends = []string{SecretBackendStore, SecretBackendVault, SecretBackendKeyVault}
+	promptStyles   = []string{PromptStyleTable, PromptStylePlain}
+)
+
+// unsupportedSettings are settings repo-config doesn't have yet, with the reason, so
+// a settings file that sets one explains why instead of calling it a typo.
+var unsupportedSettings = map[string]string{
+	"profile": "repo-config has no profiles to choose a default from",
+}
+
+// SettingsFileEnvVar names the environment variable that overrides the location of
+// the settings file.
+const SettingsFileEnvVar = "REPO_CONFIG_SETTINGS"
+
+// settingDef describes how one setting is named in the settings file, the environment
+// and on the command line.
+type settingDef struct {
+	name   string
+	envVar string
+	usage  string
+	get    func(*Settings) string
+	set    func(*Settings, string)
+}
+
+// settingDefs lists every setting, in the order they are shown.
+var settingDefs = []settingDef{
+	{
+		name:   "storeDir",
+		envVar: StoreDirEnvVar,
+		usage:  "Directory the values are stored in (default: ~/.repo-config or the XDG state directory)",
+		get:    func(s *Settings) string { return s.StoreDir },
+		set:    func(s *Settings, v string) { s.StoreDir = v },
+	},
+	{
+		name:   "outputFormats",
+		envVar: "REPO_CONFIG_OUTPUT_FORMATS",
+		usage:  "Comma separated output files to write: " + strings.Join(outputFormats, ", "),
+		get:    func(s *Settings) string { return strings.Join(s.OutputFormats, ",") },
+		set:    func(s *Settings, v string) { s.OutputFormats = splitList(v) },
+	},
+	{
+		name:   "envNaming",
+		envVar: "REPO_CONFIG_ENV_NAMING",
+		usage:  "Environment variable names of requiredAsEnv settings without one: " + strings.Join(envNamings, ", "),
+		get:    func(s *Settings) string { return s.EnvNaming },
+		set:    func(s *Settings, v string) { s.EnvNaming = v },
+	},
+	{
+		name:   "secretBackend",
+		envVar: "REPO_CONFIG_SECRET_BACKEND",
+		usage:  "Where the sources of secret settings without a scheme are looked up: " + strings.Join(secretBackends, ", "),
+		get:    func(s *Settings) string { return s.SecretBackend },
+		set:    func(s *Settings, v string) { s.SecretBackend = v },
+	},
+	{
+		name:   "promptStyle",
+		envVar: "REPO_CONFIG_PROMPT_STYLE",
+		usage:  "How the interactive prompt lists settings: " + strings.Join(promptStyles, ", "),
+		get:    func(s *Settings) string { return s.PromptStyle },
+		set:    func(s *Settings, v string) { s.PromptStyle = v },
+	},
+	{
+		name:   "scriptTimeout",
+		envVar: "REPO_CONFIG_SCRIPT_TIMEOUT",
+		usage:  "How long a shellscript, cmd source or provider plugin may run, e.g. 30s",
+		get:    func(s *Settings) string { return s.ScriptTimeout },
+		set:    func(s *Settings, v string) { s.ScriptTimeout = v },
+	},
+	{
+		name:   "lockTimeout",
+		envVar: "REPO_CONFIG_LOCK_TIMEOUT",
+		usage:  "How long to wait for another process collecting the same project, e.g. 2m",
+		get:    func(s *Settings) string { return s.LockTimeout },
+		set:    func(s *Settings, v string) { s.LockTimeout = v },
+	},
+}
+
+// Sources of a setting's value, as shown by FormatSettings.
+const (
+	sourceDefault = "default"
+	sourceFile    = "file"
+	sourceEnv     = "env"
+	sourceFlag    = "flag"
+)
+
+// SettingFlag describes the command line flag of a setting.
+type SettingFlag struct {
+	Name  string
+	Usage string
+}
+
+// SettingFlags returns the command line flags of the settings.  The flags are named
+// like the settings, in kebab case: --store-dir, --output-formats and so on.
+func SettingFlags() []SettingFlag {
+	flags := []SettingFlag{}
+	for _, def := range settingDefs {
+		flags = append(flags, SettingFlag{Name: flagName(def.name), Usage: def.usage})
+	}
+	return flags
+}
+
+// flagName turns a setting name into its flag name: storeDir -> store-dir.
+func flagName(name string) string {
+	var flag strings.Builder
+	for _, r := range name {
+		if unicode.IsUpper(r) {
+			flag.WriteByte('-')
+			r = unicode.ToLower(r)
+		}
+		flag.WriteRune(r)
+	}
+	return flag.String()
+}
+
+// defaultSettings returns the settings used when nothing overrides them.  StoreDir is
+// empty, which lets StoreDir pick the directory.
+func defaultSettings() Settings {
+	return Settings{
+		OutputFormats: []string{OutputFormatJSON, OutputFormatEnv},
+		EnvNaming:     EnvNamingNone,
+		SecretBackend: SecretBackendStore,
+		PromptStyle:   PromptStyleTable,
+		ScriptTimeout: "30s",
+		LockTimeout:   "5m",
+	}
+}
+
+// activeSettings are the settings used by this package; see UseSettings.
+var activeSettings = defaultSettings()
+
+// UseSettings makes s the settings used by this package.
+func UseSettings(s Settings) {
+	activeSettings = s
+}
+
+// SettingsFile returns the default location of the settings file:
+// $REPO_CONFIG_SETTINGS, or config.yaml in the repo-config directory of
+// $XDG_CONFIG_HOME, which defaults to ~/.config.
+func SettingsFile() (string, error) {
+	if path := os.Getenv(SettingsFileEnvVar); path != "" {
+		return filepath.Abs(path)
+	}
+	configDir, err := userConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "config.yaml"), nil
+}
+
+// userConfigDir returns the directory of repo-config's user-wide files:
+// $XDG_CONFIG_HOME/repo-config, or ~/.config/repo-config.
+func userConfigDir() (string, error) {
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if !filepath.IsAbs(configHome) {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get home directory: %v", err)
+		}
+		configHome = filepath.Join(homeDir, ".config")
+	}
+	return filepath.Join(configHome, "repo-config"), nil
+}
+
+// LoadSettings returns the settings from the settings file at path, or at
+// SettingsFile if path is empty, overridden by their environment variables and then
+// by flags, which maps flag names (see SettingFlags) to the values given on the
+// command line.  A missing settings file is only an error if path names it.  The
+// second result records where each setting came from.
+func LoadSettings(path string, flags map[string]string) (Settings, map[string]string, error) {
+	settings := defaultSettings()
+	sources := map[string]string{}
+	for _, def := range settingDefs {
+		sources[def.name] = sourceDefault
+	}
+
+	fileSettings, err := readSettingsFile(path)
+	if err != nil {
+		return Settings{}, nil, err
+	}
+	for _, def := range settingDefs {
+		if value := def.get(&fileSettings); value != "" {
+			def.set(&settings, value)
+			sources[def.name] = sourceFile
+		}
+	}
+
+	for _, def := range settingDefs {
+		if value := os.Getenv(def.envVar); value != "" {
+			def.set(&settings, value)
+			sources[def.name] = sourceEnv
+		}
+	}
+
+	for _, def := range settingDefs {
+		if value, exists := flags[flagName(def.name)]; exists {
+			def.set(&settings, value)
+			sources[def.name] = sourceFlag
+		}
+	}
+
+	if err := settings.validate(); err != nil {
+		return Settings{}, nil, err
+	}
+	return settings, sources, nil
+}
+
+// readSettingsFile reads the settings file at path, or at SettingsFile if path is
+// empty.  Unknown settings are an error so that typos don't go unnoticed.
+func readSettingsFile(path string) (Settings, error) {
+	explicit := path != ""
+	if !explicit {
+		var err error
+		if path, err = SettingsFile(); err != nil {
+			return Settings{}, err
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) && !explicit {
+		return Settings{}, nil
+	} else if err != nil {
+		return Settings{}, fmt.Errorf("failed to read settings file: %v", err)
+	}
+
+	var names map[string]interface{}
+	if err := yaml.Unmarshal(data, &names); err == nil {
+		for name, reason := range unsupportedSettings {
+			if _, exists := names[name]; exists {
+				return Settings{}, fmt.Errorf("setting '%s' in settings file '%s' is not supported: %s", name, path, reason)
+			}
+		}
+	}
+
+	var settings Settings
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&settings); err != nil && !errors.Is(err, io.EOF) {
+		return Settings{}, fmt.Errorf("failed to parse settings file '%s': %v", path, err)
+	}
+	return settings, nil
+}
+
+// validate checks that the enumerated settings have known values.
+func (s Settings) validate() error {
+	for _, format := range s.OutputFormats {
+		if !slices.Contains(outputFormats, format) {
+			return fmt.Errorf("unknown output format '%s': expected %s", format, strings.Join(outputFormats, ", "))
+		}
+	}
+	if !slices.Contains(s.OutputFormats, OutputFormatJSON) {
+		return fmt.Errorf("outputFormats must include %s, which holds the collected values", OutputFormatJSON)
+	}
+	checks := []struct {
+		name    string
+		value   string
+		allowed []string
+	}{
+		{"envNaming", s.EnvNaming, envNamings},
+		{"secretBackend", s.SecretBackend, secretBackends},
+		{"promptStyle", s.PromptStyle, promptStyles},
+	}
+	for _, check := range checks {
+		if !slices.Contains(check.allowed, check.value) {
+			return fmt.Errorf("unknown %s '%s': expected %s", check.name, check.value, strings.Join(check.allowed, ", "))
+		}
+	}
+	if timeout, err := time.ParseDuration(s.ScriptTimeout); err != nil || timeout <= 0 {
+		return fmt.Errorf("invalid scriptTimeout '%s': expected a positive duration such as 30s", s.ScriptTimeout)
+	}
+	if timeout, err := time.ParseDuration(s.LockTimeout); err != nil || timeout < 0 {
+		return fmt.Errorf("invalid lockTimeout '%s': expected a duration such as 2m", s.LockTimeout)
+	}
+	return nil
+}
+
+// scriptTimeout returns ScriptTimeout as a duration.  The settings have been
+// validated, so it parses.
+func (s Settings) scriptTimeout() time.Duration {
+	timeout, _ := time.ParseDuration(s.ScriptTimeout)
+	return timeout
+}
+
+// lockTimeout returns LockTimeout as a duration.  The settings have been validated,
+// so it parses.
+func (s Settings) lockTimeout() time.Duration {
+	timeout, _ := time.ParseDuration(s.LockTimeout)
+	return timeout
+}
+
+// writesEnvFile reports whether the env output file is written.
+func (s Settings) writesEnvFile() bool {
+	return slices.Contains(s.OutputFormats, OutputFormatEnv)
+}
+
+// FormatSettings renders the settings, where each came from and the settings file as
+// the JSON document printed by `repo-config config show`.
+func FormatSettings(settings Settings, sources map[string]string, path string) (string, error) {
+	output := struct {
+		File     string            `json:"file"`
+		Settings Settings          `json:"settings"`
+		Sources  map[string]string `json:"sources"`
+	}{path, settings, sources}
+	if output.File == "" {
+		var err error
+		if output.File, err = SettingsFile(); err != nil {
+			return "", err
+		}
+	}
+	content, err := json.MarshalIndent(output, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(content) + "\n", nil
+}
+
+// envVarName returns the environment variable item is written to in the env file,
+// or "" if it has none: its tempEnvironmentVariableName, or for requiredAsEnv items
+// a name derived from key by the envNaming setting.
+func envVarName(key string, item ItemConfig) string {
+	if item.TempEnvironmentVariableName != "" || !item.RequiredAsEnv {
+		return item.TempEnvironmentVariableName
+	}
+	switch activeSettings.EnvNaming {
+	case EnvNamingKey:
+		return key
+	case EnvNamingUpperSnake:
+		return upperSnake(key)
+	}
+	return ""
+}
+
+// upperSnake converts a setting name to an environment variable name:
+// dbPassword -> DB_PASSWORD, api-key -> API_KEY, HTTPProxy -> HTTP_PROXY.
+func upperSnake(name string) string {
+	var result strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		switch {
+		case r == '-' || r == '.' || r == ' ':
+			result.WriteByte('_')
+		case unicode.IsUpper(r) && i > 0 && wordStart(runes, i):
+			result.WriteByte('_')
+			result.WriteRune(r)
+		default:
+			result.WriteRune(unicode.ToUpper(r))
+		}
+	}
+	return result.String()
+}
+
+// wordStart reports whether the upper case rune at i starts a word: it follows a
+// lower case letter or digit, or ends an acronym (the P of HTTPProxy).
+func wordStart(runes []rune, i int) bool {
+	previous := runes[i-1]
+	if unicode.IsLower(previous) || unicode.IsDigit(previous) {
+		return true
+	}
+	return unicode.IsUpper(previous) && i+1 < len(runes) && unicode.IsLower(runes[i+1])
+}
+
+// splitList splits a comma separated list, dropping blanks.
+func splitList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,125 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadSettingsPrecedence(t *testing.T) {
+	home := t.TempDir()
+	setStoreEnv(t, home)
+	t.Setenv(SettingsFileEnvVar, "")
+	t.Setenv("REPO_CONFIG_PROMPT_STYLE", "")
+	t.Setenv("REPO_CONFIG_SCRIPT_TIMEOUT", "")
+	t.Setenv("REPO_CONFIG_SECRET_BACKEND", "")
+
+	// Without a settings file, everything is a default.
+	settings, sources, err := LoadSettings("", nil)
+	if err != nil {
+		t.Fatalf("Failed to load settings: %v", err)
+	}
+	if settings.PromptStyle != PromptStyleTable || sources["promptStyle"] != sourceDefault {
+		t.Errorf("Expected the default prompt style, got %s from %s", settings.PromptStyle, sources["promptStyle"])
+	}
+
+	writeFiles(t, home, map[string]string{".config/repo-config/config.yaml": `
+storeDir: /from/file
+promptStyle: plain
+scriptTimeout: 1m
+outputFormats: [json]
+secretBackend: keyvault
+`})
+	t.Setenv(StoreDirEnvVar, "/from/env")
+	t.Setenv("REPO_CONFIG_SECRET_BACKEND", SecretBackendVault)
+	t.Setenv("REPO_CONFIG_SCRIPT_TIMEOUT", "2m")
+	settings, sources, err = LoadSettings("", map[string]string{"script-timeout": "3m"})
+	if err != nil {
+		t.Fatalf("Failed to load settings: %v", err)
+	}
+
+	expected := map[string][2]string{
+		"storeDir":      {"/from/env", sourceEnv},
+		"promptStyle":   {"plain", sourceFile},
+		"scriptTimeout": {"3m", sourceFlag},
+		"outputFormats": {"json", sourceFile},
+		"envNaming":     {EnvNamingNone, sourceDefault},
+		"secretBackend": {SecretBackendVault, sourceEnv},
+	}
+	for _, def := range settingDefs {
+		want, exists := expected[def.name]
+		if !exists {
+			continue
+		}
+		if value := def.get(&settings); value != want[0] || sources[def.name] != want[1] {
+			t.Errorf("%s: expected %s from %s, got %s from %s", def.name, want[0], want[1], value, sources[def.name])
+		}
+	}
+	if settings.writesEnvFile() {
+		t.Error("Expected no env file without the env output format")
+	}
+
+	// An explicit settings file must exist.
+	if _, _, err := LoadSettings(filepath.Join(home, "missing.yaml"), nil); err == nil {
+		t.Error("Expected an error for a missing settings file")
+	}
+}
+
+func TestLoadSettingsErrors(t *testing.T) {
+	home := t.TempDir()
+	setStoreEnv(t, home)
+	path := filepath.Join(home, "settings.yaml")
+
+	tests := map[string]string{
+		"promptStyle: fancy\n":       "unknown promptStyle 'fancy'",
+		"outputFormats: [env]\n":     "must include json",
+		"outputFormats: [json, x]\n": "unknown output format 'x'",
+		"scriptTimeout: soon\n":      "invalid scriptTimeout",
+		"promptstyle: plain\n":       "field promptstyle not found",
+		"secretBackend: s3\n":        "unknown secretBackend 's3'",
+		"profile: dev\n":             "setting 'profile' in settings file",
+	}
+	for content, expected := range tests {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write settings: %v", err)
+		}
+		if _, _, err := LoadSettings(path, nil); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("%q: expected error containing %q, got %v", content, expected, err)
+		}
+	}
+}
+
+func TestEnvNaming(t *testing.T) {
+	defer UseSettings(defaultSettings())
+	names := map[string]string{
+		"dbPassword":    "DB_PASSWORD",
+		"api-key":       "API_KEY",
+		"HTTPProxy":     "HTTP_PROXY",
+		"region2Name":   "REGION2_NAME",
+		"ALREADY_UPPER": "ALREADY_UPPER",
+	}
+	for key, expected := range names {
+		if name := upperSnake(key); name != expected {
+			t.Errorf("%s: expected %s, got %s", key, expected, name)
+		}
+	}
+
+	named := ItemConfig{TempEnvironmentVariableName: "DATABASE", RequiredAsEnv: true}
+	unnamed := ItemConfig{RequiredAsEnv: true}
+	if name := envVarName("dbPassword", unnamed); name != "" {
+		t.Errorf("Expected no name by default, got %s", name)
+	}
+	settings := defaultSettings()
+	settings.EnvNaming = EnvNamingUpperSnake
+	UseSettings(settings)
+	if name := envVarName("dbPassword", unnamed); name != "DB_PASSWORD" {
+		t.Errorf("Expected DB_PASSWORD, got %s", name)
+	}
+	if name := envVarName("dbPassword", named); name != "DATABASE" {
+		t.Errorf("Expected the explicit name to win, got %s", name)
+	}
+	if name := envVarName("dbPassword", ItemConfig{}); name != "" {
+		t.Errorf("Expected no name for settings not required as env, got %s", name)
+	}
+}
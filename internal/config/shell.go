@@ -0,0 +1,183 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Shells that statements can be written for.
+const (
+	ShellBash       = "bash"
+	ShellZsh        = "zsh"
+	ShellFish       = "fish"
+	ShellPowerShell = "powershell"
+	ShellNushell    = "nushell"
+)
+
+// Shells lists the shells statements can be written for.
+var Shells = []string{ShellBash, ShellZsh, ShellFish, ShellPowerShell, ShellNushell}
+
+// envNamePattern matches the environment variable names that are written into
+// statements.  Anything else could inject commands into the shell that evaluates them.
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// DefaultShell returns the shell named by $SHELL if statements can be written for
+// it, and bash otherwise.
+func DefaultShell() string {
+	name := strings.TrimSuffix(filepath.Base(os.Getenv("SHELL")), ".exe")
+	switch name {
+	case "pwsh":
+		return ShellPowerShell
+	case "nu":
+		return ShellNushell
+	}
+	for _, shell := range Shells {
+		if name == shell {
+			return shell
+		}
+	}
+	return ShellBash
+}
+
+// FormatExports returns the statements that set vars in shell, one per line.  Nushell
+// can't evaluate statements, so for it they are a JSON record for load-env.
+func FormatExports(shell string, vars []EnvVar) (string, error) {
+	if err := checkShell(shell); err != nil {
+		return "", err
+	}
+	for _, v := range vars {
+		if err := checkEnvName(v.Name); err != nil {
+			return "", err
+		}
+	}
+
+	if shell == ShellNushell {
+		record := make(map[string]string)
+		for _, v := range vars {
+			record[v.Name] = v.Value
+		}
+		return marshalNushell(record)
+	}
+
+	var statements strings.Builder
+	for _, v := range vars {
+		switch shell {
+		case ShellBash, ShellZsh:
+			fmt.Fprintf(&statements, "export %s=%s\n", v.Name, quotePOSIX(v.Value))
+		case ShellFish:
+			fmt.Fprintf(&statements, "set -gx %s %s\n", v.Name, quoteFish(v.Value))
+		case ShellPowerShell:
+			fmt.Fprintf(&statements, "$env:%s = %s\n", v.Name, quotePowerShell(v.Value))
+		}
+	}
+	return statements.String(), nil
+}
+
+// FormatUnsets returns the statements that remove the variables names from shell,
+// one per line.  For nushell they are a JSON list for hide-env.
+func FormatUnsets(shell string, names []string) (string, error) {
+	if err := checkShell(shell); err != nil {
+		return "", err
+	}
+	for _, name := range names {
+		if err := checkEnvName(name); err != nil {
+			return "", err
+		}
+	}
+
+	if shell == ShellNushell {
+		return marshalNushell(names)
+	}
+
+	var statements strings.Builder
+	for _, name := range names {
+		switch shell {
+		case ShellBash, ShellZsh:
+			fmt.Fprintf(&statements, "unset %s\n", name)
+		case ShellFish:
+			fmt.Fprintf(&statements, "set -e %s\n", name)
+		case ShellPowerShell:
+			fmt.Fprintf(&statements, "Remove-Item Env:%s -ErrorAction SilentlyContinue\n", name)
+		}
+	}
+	return statements.String(), nil
+}
+
+// checkShell returns an error for shells statements can't be written for.
+func checkShell(shell string) error {
+	for _, s := range Shells {
+		if shell == s {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown shell '%s': expected %s", shell, strings.Join(Shells, ", "))
+}
+
+// checkEnvName returns an error for names that aren't safe to write into statements.
+func checkEnvName(name string) error {
+	if !envNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid environment variable name '%s'", name)
+	}
+	return nil
+}
+
+// quotePOSIX quotes value for bash and zsh: single quotes keep everything literal,
+// and a single quote closes them, is escaped and opens them again:
+//
+//	it's -> 'it'\''s'
+func quotePOSIX(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// quoteFish quotes value for fish, where only \ and ' are special in single quotes.
+func quoteFish(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	return "'" + strings.ReplaceAll(value, "'", `\'`) + "'"
+}
+
+// quotePowerShell quotes value for PowerShell, where a single quote in single quotes
+// is written twice.  PowerShell also takes typographic single quotes for them.
+func quotePowerShell(value string) string {
+	var quoted strings.Builder
+	quoted.WriteByte('\'')
+	for _, r := range value {
+		switch r {
+		case '\'', '\u2018', '\u2019', '\u201a', '\u201b':
+			quoted.WriteRune(r)
+		}
+		quoted.WriteRune(r)
+	}
+	quoted.WriteByte('\'')
+	return quoted.String()
+}
+
+// marshalNushell renders value as the JSON nushell reads with from json.
+func marshalNushell(value interface{}) (string, error) {
+	content, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(content) + "\n", nil
+}
+
+// EnvVarNames returns the names of every environment variable inputJSONFile can
+// define, active or not, so they can all be removed.  It doesn't read any values.
+func EnvVarNames(inputJSONFile string) ([]string, error) {
+	configMap, err := loadConfigFile(inputJSONFile)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	seen := make(map[string]bool)
+	for _, key := range orderedKeys(configMap) {
+		if name := envVarName(key, configMap[key]); name != "" && !seen[name] {
+			names = append(names, name)
+			seen[name] = true
+		}
+	}
+	return names, nil
+}
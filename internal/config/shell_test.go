@@ -0,0 +1,57 @@
+package config
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestFormatExports(t *testing.T) {
+	vars := []EnvVar{{"PLAIN", "value"}, {"TRICKY", `it's "$HOME" \n ‘x’`}}
+	expected := map[string]string{
+		ShellBash:       "export PLAIN='value'\nexport TRICKY='it'\\''s \"$HOME\" \\n ‘x’'\n",
+		ShellFish:       "set -gx PLAIN 'value'\nset -gx TRICKY 'it\\'s \"$HOME\" \\\\n ‘x’'\n",
+		ShellPowerShell: "$env:PLAIN = 'value'\n$env:TRICKY = 'it''s \"$HOME\" \\n ‘‘x’’'\n",
+		ShellNushell:    "{\"PLAIN\":\"value\",\"TRICKY\":\"it's \\\"$HOME\\\" \\\\n ‘x’\"}\n",
+	}
+	for shell, want := range expected {
+		got, err := FormatExports(shell, vars)
+		if err != nil {
+			t.Errorf("%s: expected no error, got %v", shell, err)
+		} else if got != want {
+			t.Errorf("%s: expected %q, got %q", shell, want, got)
+		}
+	}
+
+	unsets, err := FormatUnsets(ShellFish, []string{"PLAIN", "TRICKY"})
+	if err != nil || unsets != "set -e PLAIN\nset -e TRICKY\n" {
+		t.Errorf("Expected fish unsets, got %q (%v)", unsets, err)
+	}
+
+	if _, err := FormatExports("tcsh", vars); err == nil {
+		t.Error("Expected an error for an unknown shell")
+	}
+	if _, err := FormatExports(ShellBash, []EnvVar{{"A; rm -rf ~", "x"}}); err == nil {
+		t.Error("Expected an error for a name that isn't safe to evaluate")
+	}
+}
+
+func TestFormatExportsRoundTrip(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not installed")
+	}
+	value := "it's \"$HOME\" `date` \\ \n second line"
+	exports, err := FormatExports(ShellBash, []EnvVar{{"REPO_CONFIG_TEST", value}})
+	if err != nil {
+		t.Fatalf("Failed to format exports: %v", err)
+	}
+	cmd := exec.Command("sh", "-c", `eval "$(cat)"; printf '%s' "$REPO_CONFIG_TEST"`)
+	cmd.Stdin = strings.NewReader(exports)
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("Failed to evaluate exports: %v", err)
+	}
+	if string(output) != value {
+		t.Errorf("Expected %q, got %q", value, output)
+	}
+}
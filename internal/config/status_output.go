@@ -1,6 +1,7 @@
 package config
 import (
     "encoding/json"
+    "errors"
     "fmt"
 )
 
@@ -51,6 +52,10 @@ type StatusOutput struct {
     Message   string `json:"message"`    // Descriptive status message
     EnvFile   string `json:"env_file"`   // Path to the .env file
     JSONFile  string `json:"json_file"`  // Path to the JSON output file
+    InputFile string `json:"input_file,omitempty"` // Input file, when several are processed
+    EnvrcFile string `json:"envrc_file,omitempty"` // .envrc written by collect --envrc
+    Errors    []SourceError `json:"errors,omitempty"`  // Settings whose source failed
+    Results   []StatusOutput `json:"results,omitempty"` // Per-file results of collect --all
 }
 
 //
@@ -62,8 +67,48 @@ func CreateSuccessOutput(jsonFile, envFile string) string {
 // 
 //	creates an error output
 func CreateErrorOutput(err error) string {
-	msg := fmt.Sprintf("%s", err)
-	return createStatusOutput(false, msg, "", "")
+	return marshalStatusOutput(newErrorStatus(err))
+}
+
+// CreateSuccessOutputForInput creates a success output that also names the input
+// file, for when it was found automatically instead of being passed with --json.
+func CreateSuccessOutputForInput(inputFile, jsonFile, envFile string) string {
+	output := newSuccessStatus(jsonFile, envFile)
+	output.InputFile = inputFile
+	return marshalStatusOutput(output)
+}
+
+// CreateAggregateOutput creates the output for several input files.  The overall
+// status is an error if any of the files failed.
+func CreateAggregateOutput(results []StatusOutput) string {
+	output := newSuccessStatus("", "")
+	failed := 0
+	for _, result := range results {
+		if result.Status != StatusOK {
+			failed++
+		}
+	}
+	if failed > 0 {
+		output = newErrorStatus(fmt.Errorf("%d of %d input files failed", failed, len(results)))
+	}
+	output.Results = results
+	return marshalStatusOutput(output)
+}
+
+// newSuccessStatus returns a successful StatusOutput for the given output files.
+func newSuccessStatus(jsonFile, envFile string) StatusOutput {
+	return StatusOutput{Status: StatusOK, EnvFile: envFile, JSONFile: jsonFile}
+}
+
+// newErrorStatus returns a StatusOutput for err, listing the settings that failed
+// to resolve if that is what it was.
+func newErrorStatus(err error) StatusOutput {
+	output := StatusOutput{Status: StatusError, Message: fmt.Sprintf("%s", err)}
+	var sourceErrors SourceErrors
+	if errors.As(err, &sourceErrors) {
+		output.Errors = sourceErrors
+	}
+	return output
 }
 
 
@@ -84,6 +129,17 @@ func createStatusOutput(success bool, message, jsonFile, envFile string) string
         JSONFile: jsonFile,
     }
 
+    return marshalStatusOutput(output)
+}
+
+// String renders the status as JSON, the way it is printed.
+func (output StatusOutput) String() string {
+    return marshalStatusOutput(output)
+}
+
+// marshalStatusOutput renders output as JSON.  In case of marshalling failure, it
+// returns a default JSON error message.
+func marshalStatusOutput(output StatusOutput) string {
     jsonBytes, err := json.Marshal(output)
     if err != nil {
         return fmt.Sprintf(`
@@ -0,0 +1,192 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// StoreDirEnvVar names the environment variable of the storeDir setting.
+const StoreDirEnvVar = "REPO_CONFIG_HOME"
+
+// legacyStoreName is the directory under the home directory that values were always
+// stored in before the store became configurable, and still are by default.
+const legacyStoreName = ".repo-config"
+
+// StoreDir returns the directory the values of every project are stored in, one
+// subdirectory per project.  The first of these wins:
+//   - the storeDir setting (--store-dir, $REPO_CONFIG_HOME or the settings file),
+//   - ~/.repo-config, if it exists, so existing values keep working until they are
+//     moved with MigrateStore,
+//   - $XDG_STATE_HOME/repo-config, then $XDG_CONFIG_HOME/repo-config/values,
+//   - ~/.repo-config.
+func StoreDir() (string, error) {
+	if activeSettings.StoreDir == "" {
+		legacyDir, err := legacyStoreDir()
+		if err != nil {
+			return "", err
+		}
+		if info, err := os.Lstat(legacyDir); err == nil && info.IsDir() {
+			return legacyDir, nil
+		}
+	}
+	return configuredStoreDir()
+}
+
+// configuredStoreDir returns the store directory StoreDir would use if ~/.repo-config
+// didn't exist.
+func configuredStoreDir() (string, error) {
+	if activeSettings.StoreDir != "" {
+		return filepath.Abs(activeSettings.StoreDir)
+	}
+	if dir := xdgStoreDir(); dir != "" {
+		return dir, nil
+	}
+	return legacyStoreDir()
+}
+
+// legacyStoreDir returns ~/.repo-config.
+func legacyStoreDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %v", err)
+	}
+	return filepath.Join(homeDir, legacyStoreName), nil
+}
+
+// xdgStoreDir returns the repo-config directory of the XDG state home, or the values
+// directory in the repo-config directory of the XDG config home, or "" if neither is
+// set.  Values are state rather than configuration, so the state home is preferred.
+// In the config home they get a directory of their own, apart from config.yaml and
+// trust.json.
+func xdgStoreDir() string {
+	// The XDG spec says relative paths are invalid and should be ignored.
+	if dir := os.Getenv("XDG_STATE_HOME"); filepath.IsAbs(dir) {
+		return filepath.Join(dir, "repo-config")
+	}
+	if dir := os.Getenv("XDG_CONFIG_HOME"); filepath.IsAbs(dir) {
+		return filepath.Join(dir, "repo-config", "values")
+	}
+	return ""
+}
+
+// MigrateStore moves the values stored in ~/.repo-config to the configured store
+// directory (see StoreDir) and leaves a symlink to it behind, so scripts that read
+// ~/.repo-config keep working.  Projects that already exist in the destination are
+// not overwritten; the migration stops with an error naming them.  It returns the
+// destination directory.
+func MigrateStore() (string, error) {
+	legacyDir, err := legacyStoreDir()
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Lstat(legacyDir)
+	if os.IsNotExist(err) || (err == nil && info.Mode()&os.ModeSymlink != 0) {
+		return "", fmt.Errorf("nothing to migrate: '%s' does not exist or was already migrated", legacyDir)
+	} else if err != nil {
+		return "", err
+	}
+
+	storeDir, err := configuredStoreDir()
+	if err != nil {
+		return "", err
+	}
+	if storeDir == legacyDir {
+		return "", fmt.Errorf("nothing to migrate: set --store-dir, %s, storeDir in the settings file, XDG_STATE_HOME or XDG_CONFIG_HOME to choose where to move '%s'", StoreDirEnvVar, legacyDir)
+	}
+
+	entries, err := os.ReadDir(legacyDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to read '%s': %v", legacyDir, err)
+	}
+	conflicts := []string{}
+	for _, entry := range entries {
+		if _, err := os.Lstat(filepath.Join(storeDir, entry.Name())); err == nil {
+			conflicts = append(conflicts, entry.Name())
+		}
+	}
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return "", fmt.Errorf("'%s' already has values for %s", storeDir, strings.Join(conflicts, ", "))
+	}
+
+	if err := os.MkdirAll(storeDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory '%s': %v", storeDir, err)
+	}
+	for _, entry := range entries {
+		if err := movePath(filepath.Join(legacyDir, entry.Name()), filepath.Join(storeDir, entry.Name())); err != nil {
+			return "", err
+		}
+	}
+	if err := os.Remove(legacyDir); err != nil {
+		return "", fmt.Errorf("failed to remove '%s': %v", legacyDir, err)
+	}
+	if err := os.Symlink(storeDir, legacyDir); err != nil {
+		return "", fmt.Errorf("failed to link '%s' to '%s': %v", legacyDir, storeDir, err)
+	}
+	return storeDir, nil
+}
+
+// movePath moves src to dst.  Renaming fails across file systems, e.g. onto a volume
+// mounted into a devcontainer, so it falls back to copying and removing src.
+func movePath(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	if err := copyPath(src, dst); err != nil {
+		os.RemoveAll(dst)
+		return fmt.Errorf("failed to copy '%s' to '%s': %v", src, dst, err)
+	}
+	if err := os.RemoveAll(src); err != nil {
+		return fmt.Errorf("failed to remove '%s': %v", src, err)
+	}
+	return nil
+}
+
+// copyPath copies the file, symlink or directory tree src to dst.
+func copyPath(src, dst string) error {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(target, dst)
+	case info.IsDir():
+		if err := os.Mkdir(dst, info.Mode().Perm()); err != nil {
+			return err
+		}
+		entries, err := os.ReadDir(src)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if err := copyPath(filepath.Join(src, entry.Name()), filepath.Join(dst, entry.Name())); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		in, err := os.Open(src)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, in); err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
+	}
+}
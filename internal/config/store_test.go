@@ -0,0 +1,131 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// setStoreEnv points HOME at home and clears every other setting that chooses the
+// store directory.
+func setStoreEnv(t *testing.T, home string) {
+	t.Helper()
+	t.Setenv("HOME", home)
+	t.Setenv(StoreDirEnvVar, "")
+	t.Setenv("XDG_STATE_HOME", "")
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Cleanup(func() { UseSettings(defaultSettings()) })
+}
+
+// setStoreDir makes dir the storeDir setting.
+func setStoreDir(dir string) {
+	settings := defaultSettings()
+	settings.StoreDir = dir
+	UseSettings(settings)
+}
+
+func TestStoreDir(t *testing.T) {
+	home := t.TempDir()
+	setStoreEnv(t, home)
+	legacyDir := filepath.Join(home, ".repo-config")
+
+	expect := func(expected string) {
+		t.Helper()
+		dir, err := StoreDir()
+		if err != nil {
+			t.Fatalf("Failed to get store dir: %v", err)
+		}
+		if dir != expected {
+			t.Errorf("Expected %s, got %s", expected, dir)
+		}
+	}
+
+	expect(legacyDir)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, "config"))
+	expect(filepath.Join(home, "config", "repo-config", "values"))
+	t.Setenv("XDG_STATE_HOME", filepath.Join(home, "state"))
+	expect(filepath.Join(home, "state", "repo-config"))
+	t.Setenv("XDG_STATE_HOME", "relative/state")
+	expect(filepath.Join(home, "config", "repo-config", "values"))
+
+	// Existing values win over the XDG directories until they are migrated ...
+	if err := os.Mkdir(legacyDir, 0755); err != nil {
+		t.Fatalf("Failed to create %s: %v", legacyDir, err)
+	}
+	expect(legacyDir)
+
+	// ... but not over an explicit choice.
+	setStoreDir(filepath.Join(home, "volume"))
+	expect(filepath.Join(home, "volume"))
+}
+
+func TestMigrateStore(t *testing.T) {
+	home := t.TempDir()
+	setStoreEnv(t, home)
+	legacyDir := filepath.Join(home, ".repo-config")
+
+	if _, err := MigrateStore(); err == nil {
+		t.Error("Expected an error without values to migrate")
+	}
+
+	writeFiles(t, legacyDir, map[string]string{
+		"github.com_contoso_api/.settings-values.json": `{"key": "value"}`,
+		"github.com_contoso_web/.settings-values.json": `{}`,
+	})
+	if err := os.Symlink("github.com_contoso_api", filepath.Join(legacyDir, "api")); err != nil {
+		t.Fatalf("Failed to create alias: %v", err)
+	}
+
+	if _, err := MigrateStore(); err == nil {
+		t.Error("Expected an error without a destination")
+	}
+
+	volume := filepath.Join(home, "volume")
+	writeFiles(t, volume, map[string]string{"github.com_contoso_web/.settings-values.json": `{}`})
+	setStoreDir(volume)
+	if _, err := MigrateStore(); err == nil {
+		t.Error("Expected an error for a project that exists in both places")
+	}
+	os.RemoveAll(filepath.Join(volume, "github.com_contoso_web"))
+
+	storeDir, err := MigrateStore()
+	if err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	if storeDir != volume {
+		t.Errorf("Expected %s, got %s", volume, storeDir)
+	}
+	content, err := os.ReadFile(filepath.Join(volume, "api", ".settings-values.json"))
+	if err != nil || string(content) != `{"key": "value"}` {
+		t.Errorf("Expected migrated values through the alias, got %q (%v)", content, err)
+	}
+	if target, err := os.Readlink(legacyDir); err != nil || target != volume {
+		t.Errorf("Expected %s to link to %s, got %q (%v)", legacyDir, volume, target, err)
+	}
+
+	// The symlink left behind is not mistaken for unmigrated values.
+	setStoreDir("")
+	t.Setenv("XDG_STATE_HOME", volume)
+	if dir, err := StoreDir(); err != nil || dir != filepath.Join(volume, "repo-config") {
+		t.Errorf("Expected the XDG store dir, got %s (%v)", dir, err)
+	}
+}
+
+func TestCopyPath(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"src/project/.settings-values.env": "KEY=value"})
+	if err := os.Symlink("project", filepath.Join(dir, "src", "alias")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+
+	// copyPath is what movePath falls back to across file systems.
+	if err := copyPath(filepath.Join(dir, "src"), filepath.Join(dir, "dst")); err != nil {
+		t.Fatalf("Failed to copy: %v", err)
+	}
+	if content, err := os.ReadFile(filepath.Join(dir, "dst", "alias", ".settings-values.env")); err != nil || string(content) != "KEY=value" {
+		t.Errorf("Expected copied values, got %q (%v)", content, err)
+	}
+	if target, err := os.Readlink(filepath.Join(dir, "dst", "alias")); err != nil || target != "project" {
+		t.Errorf("Expected the symlink to be copied, got %q (%v)", target, err)
+	}
+}
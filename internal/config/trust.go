@@ -0,0 +1,253 @@
+package config
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// trustFileName is the trust store in the user config directory (see userConfigDir).
+const trustFileName = "trust.json"
+
+// Input files are checked in, so their shellscripts and cmd sources are only run once
+// the user approved them: the first time they would run, and again whenever they
+// change.  Approvals are kept in the trust store, by repo and hash of the script.
+
+// trustStore holds the scripts the user approved.
+type trustStore struct {
+	path string
+	// Repos maps each repo (see trustRepo) to the hashes of its approved scripts.
+	Repos map[string][]string `json:"repos"`
+}
+
+// inputScript is a command in an input file that collecting can run.
+type inputScript struct {
+	key         string
+	description string
+	source      string // shellScriptSource, or the cmd source
+	script      string
+	// shell, cwd and env are the item's, which change what the script does.
+	shell string
+	cwd   string
+	env   map[string]string
+}
+
+// newInputScript returns script, the shellscript or cmd source of the item key.
+func newInputScript(key string, item ItemConfig, source, script string) inputScript {
+	return inputScript{key: key, description: item.Description, source: source, script: script,
+		shell: item.Shell, cwd: item.Cwd, env: item.Env}
+}
+
+// TrustFile returns the path of the trust store.
+func TrustFile() (string, error) {
+	configDir, err := userConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, trustFileName), nil
+}
+
+// loadTrustStore reads the trust store.  A missing one trusts nothing.
+func loadTrustStore() (*trustStore, error) {
+	path, err := TrustFile()
+	if err != nil {
+		return nil, err
+	}
+	store := &trustStore{path: path, Repos: make(map[string][]string)}
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read trust store '%s': %v", path, err)
+	}
+	if err := json.Unmarshal(content, store); err != nil {
+		return nil, fmt.Errorf("failed to parse trust store '%s': %v", path, err)
+	}
+	if store.Repos == nil {
+		store.Repos = make(map[string][]string)
+	}
+	return store, nil
+}
+
+// trusted reports whether script was approved for repo.
+func (t *trustStore) trusted(repo string, script inputScript) bool {
+	return slices.Contains(t.Repos[repo], scriptHash(script))
+}
+
+// approve records that script is approved for repo.
+func (t *trustStore) approve(repo string, script inputScript) {
+	if !t.trusted(repo, script) {
+		t.Repos[repo] = append(t.Repos[repo], scriptHash(script))
+		slices.Sort(t.Repos[repo])
+	}
+}
+
+// save writes the trust store.  Only the user may change it.
+func (t *trustStore) save() error {
+	content, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(t.path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory '%s': %v", filepath.Dir(t.path), err)
+	}
+	if err := os.WriteFile(t.path, append(content, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write trust store '%s': %v", t.path, err)
+	}
+	return nil
+}
+
+// scriptHash returns the hash script is approved by.  Its shell, cwd and env are
+// hashed with it when they are set, so changing them needs approval again.
+func scriptHash(script inputScript) string {
+	content := script.script
+	if script.shell != "" || script.cwd != "" || len(script.env) > 0 {
+		options, _ := json.Marshal(map[string]interface{}{"shell": script.shell, "cwd": script.cwd, "env": script.env})
+		content += "\x00" + string(options)
+	}
+	sum := sha256.Sum256([]byte(content))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// trustRepo returns what approvals for inputFile are kept under: the normalized
+// remote of its repo, so every clone shares them, or else the root of the repo, or
+// the directory of inputFile outside of a repo.
+func trustRepo(inputFile string) string {
+	dir := filepath.Dir(inputFile)
+	if absDir, err := filepath.Abs(dir); err == nil {
+		dir = absDir
+	}
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = resolved
+	}
+	root, err := getGitRoot(dir)
+	if err != nil {
+		return dir
+	}
+	if remote, err := getRemoteURL(root); err == nil && remote != "" {
+		if name := normalizeRemoteURL(remote); name != "" {
+			return name
+		}
+	}
+	return root
+}
+
+// untrustedMessage is the error of a script that was not approved.
+const untrustedMessage = "the script has not been approved: run repo-config collect or repo-config trust to review it"
+
+// inputScripts returns the scripts of configMap in input file order.  With pending
+// set, only those collecting may run: every cmd source, and the shellscripts of items
+// without a value.  Whether an item is active can depend on values that are only
+// resolved while collecting, so when isn't checked here; scriptRunner checks the
+// approval again before running a script.
+func inputScripts(configMap map[string]ItemConfig, pending bool) []inputScript {
+	scripts := []inputScript{}
+	for _, key := range orderedKeys(configMap) {
+		item := configMap[key]
+		if scheme, command := parseSource(item.Source); scheme == SourceCmd && command != "" {
+			scripts = append(scripts, newInputScript(key, item, item.Source, command))
+		}
+		if item.ShellScript != "" && (!pending || needsShellScript(item)) {
+			scripts = append(scripts, newInputScript(key, item, shellScriptSource, item.ShellScript))
+		}
+	}
+	return scripts
+}
+
+// approveScripts asks the user to approve the scripts collecting configMap may run
+// that weren't approved for the repo of inputFile yet, showing each one and reading
+// the answer from reader.  The ones the user turns down stay unapproved, so
+// scriptRunner refuses them and their settings are asked for instead.
+func approveScripts(inputFile string, configMap map[string]ItemConfig, reader *bufio.Reader) error {
+	scripts := inputScripts(configMap, true)
+	if len(scripts) == 0 {
+		return nil
+	}
+	store, err := loadTrustStore()
+	if err != nil {
+		return err
+	}
+	repo := trustRepo(inputFile)
+
+	approved := false
+	for _, script := range scripts {
+		if store.trusted(repo, script) {
+			continue
+		}
+		printScript(inputFile, script)
+		fmt.Fprint(os.Stderr, "Run it? Only approve scripts you have read and trust. [y/N]: ")
+		answer, err := reader.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("failed to read input: %v", err)
+		}
+		if answer = strings.ToLower(strings.TrimSpace(answer)); answer == "y" || answer == "yes" {
+			store.approve(repo, script)
+			approved = true
+		}
+	}
+	if approved {
+		return store.save()
+	}
+	return nil
+}
+
+// printScript shows script on stderr for the user to review.
+func printScript(inputFile string, script inputScript) {
+	name := script.key
+	if script.description != "" {
+		name = fmt.Sprintf("%s (%s)", script.key, script.description)
+	}
+	fmt.Fprintf(os.Stderr, "\n%s in %s runs:\n\n", name, inputFile)
+	for _, line := range strings.Split(script.script, "\n") {
+		fmt.Fprintf(os.Stderr, "    %s\n", line)
+	}
+	fmt.Fprintln(os.Stderr)
+	if script.shell != "" {
+		fmt.Fprintf(os.Stderr, "with %s\n", script.shell)
+	}
+	if script.cwd != "" {
+		fmt.Fprintf(os.Stderr, "in %s\n", script.cwd)
+	}
+	for _, name := range sortedEnvNames(script.env) {
+		fmt.Fprintf(os.Stderr, "with %s=%s\n", name, script.env[name])
+	}
+	if script.shell != "" || script.cwd != "" || len(script.env) > 0 {
+		fmt.Fprintln(os.Stderr)
+	}
+}
+
+// TrustScripts approves every script of inputJSONFile for its repo, showing the ones
+// that weren't approved yet on stderr, and returns their keys.
+func TrustScripts(inputJSONFile string) ([]string, error) {
+	configMap, err := loadConfigFile(inputJSONFile)
+	if err != nil {
+		return nil, err
+	}
+	store, err := loadTrustStore()
+	if err != nil {
+		return nil, err
+	}
+	repo := trustRepo(inputJSONFile)
+
+	approved := []string{}
+	for _, script := range inputScripts(configMap, false) {
+		if store.trusted(repo, script) {
+			continue
+		}
+		printScript(inputJSONFile, script)
+		store.approve(repo, script)
+		approved = append(approved, script.key)
+	}
+	if len(approved) > 0 {
+		if err := store.save(); err != nil {
+			return nil, err
+		}
+	}
+	return approved, nil
+}
@@ -0,0 +1,184 @@
+//go:build unix
+
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTrustTestRepo lays out the session test repo with a cmd source and a shellscript
+// that write to marker files when they run, and returns the input file.
+func newTrustTestRepo(t *testing.T) string {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	inputFile := newSessionTestRepo(t)
+	writeFiles(t, filepath.Dir(inputFile), map[string]string{filepath.Base(inputFile): `
+region:
+  default: westus
+account:
+  source: "cmd:touch cmd-ran; echo contoso"
+owner:
+  shellscript: "touch script-ran; echo me"
+`})
+	return inputFile
+}
+
+// collectWithInput collects inputFile, answering prompts with input.
+func collectWithInput(t *testing.T, inputFile string, silent bool, input string) error {
+	t.Helper()
+	s, err := newSession(inputFile)
+	if err != nil {
+		t.Fatalf("Failed to load %s: %v", inputFile, err)
+	}
+	defer s.close()
+	return s.collect(silent, strings.NewReader(input))
+}
+
+func TestSilentCollectRefusesUntrustedScripts(t *testing.T) {
+	inputFile := newTrustTestRepo(t)
+	repo := filepath.Dir(filepath.Dir(inputFile))
+
+	status := CollectConfigStatus(inputFile, true)
+	if status.Status != StatusError || len(status.Errors) != 2 {
+		t.Fatalf("Expected both scripts to be refused, got %s", status)
+	}
+	for i, source := range []string{"cmd:touch cmd-ran; echo contoso", shellScriptSource} {
+		if status.Errors[i].Source != source || status.Errors[i].Code != "untrusted" {
+			t.Errorf("Expected %s to be untrusted, got %+v", source, status.Errors[i])
+		}
+	}
+	if fileExists(filepath.Join(repo, "cmd-ran")) || fileExists(filepath.Join(repo, "script-ran")) {
+		t.Error("Expected no script to run")
+	}
+
+	approved, err := TrustScripts(inputFile)
+	if err != nil || strings.Join(approved, ",") != "account,owner" {
+		t.Fatalf("Expected both scripts to be approved, got %v (%v)", approved, err)
+	}
+	if err := collectWithInput(t, inputFile, true, ""); err != nil {
+		t.Fatalf("Expected approved scripts to run, got %v", err)
+	}
+	values := readValues(t, inputFile)
+	if values["account"] != "contoso" || values["owner"] != "me" || !fileExists(filepath.Join(repo, "cmd-ran")) {
+		t.Errorf("Expected the scripts to run in the repo root, got %v", values)
+	}
+}
+
+func TestInteractiveCollectApprovesScripts(t *testing.T) {
+	inputFile := newTrustTestRepo(t)
+
+	repo := filepath.Dir(filepath.Dir(inputFile))
+
+	// Turning one down runs the other and asks for the value instead.
+	s, err := newSession(inputFile)
+	if err != nil {
+		t.Fatalf("Failed to load %s: %v", inputFile, err)
+	}
+	err = s.collect(false, strings.NewReader("y\nn\ns\nc\n"))
+	s.close()
+	if err != nil {
+		t.Fatalf("Expected collect to go on without the refused script, got %v", err)
+	}
+	if len(s.scriptErrors) != 1 || s.scriptErrors[0].Key != "owner" || s.scriptErrors[0].Code != "untrusted" {
+		t.Errorf("Expected the refused script to be reported, got %v", s.scriptErrors)
+	}
+	if !fileExists(filepath.Join(repo, "cmd-ran")) || fileExists(filepath.Join(repo, "script-ran")) {
+		t.Error("Expected only the approved script to run")
+	}
+	if values := readValues(t, inputFile); values["account"] != "contoso" || values["owner"] != "" {
+		t.Errorf("Expected only the approved script to set its value, got %v", values)
+	}
+
+	// The approved one isn't asked about again.
+	if err := collectWithInput(t, inputFile, false, "y\ns\nc\n"); err != nil {
+		t.Fatalf("Expected collect to succeed, got %v", err)
+	}
+	if values := readValues(t, inputFile); values["owner"] != "me" {
+		t.Errorf("Expected the shellscript to set owner, got %v", values)
+	}
+
+	// The shellscript doesn't run again once owner has a value, but a changed
+	// command needs approval again.
+	writeFiles(t, filepath.Dir(inputFile), map[string]string{filepath.Base(inputFile): `
+region:
+  default: westus
+account:
+  source: "cmd:echo fabrikam"
+owner:
+  shellscript: "touch script-ran; echo me"
+`})
+	status := CollectConfigStatus(inputFile, true)
+	if len(status.Errors) != 1 || status.Errors[0].Key != "account" || status.Errors[0].Code != "untrusted" {
+		t.Errorf("Expected only the changed command to be untrusted, got %s", status)
+	}
+}
+
+func TestCollectRefusesScriptsGatedOnResolvedValues(t *testing.T) {
+	inputFile := newTrustTestRepo(t)
+	repo := filepath.Dir(filepath.Dir(inputFile))
+	writeFiles(t, filepath.Dir(inputFile), map[string]string{filepath.Base(inputFile): `{
+		"mode": {"source": "env:MODE"},
+		"evil": {"shellscript": "touch PWNED; echo x", "when": "mode == on"}
+	}`})
+	t.Setenv("MODE", "on")
+
+	status := CollectConfigStatus(inputFile, true)
+	if status.Status != StatusError || len(status.Errors) != 1 || status.Errors[0].Key != "evil" || status.Errors[0].Code != "untrusted" {
+		t.Errorf("Expected the gated script to be untrusted, got %s", status)
+	}
+	if fileExists(filepath.Join(repo, "PWNED")) {
+		t.Error("Expected the gated script not to run")
+	}
+}
+
+func TestSilentCollectIgnoresInactiveUntrustedScripts(t *testing.T) {
+	inputFile := newTrustTestRepo(t)
+	repo := filepath.Dir(filepath.Dir(inputFile))
+	writeFiles(t, filepath.Dir(inputFile), map[string]string{filepath.Base(inputFile): `
+region:
+  default: westus
+evil:
+  shellscript: "touch PWNED; echo x"
+  when: region == eastus
+`})
+	// Keep the input older than the values, so only missing values would prompt.
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(inputFile, past, past); err != nil {
+		t.Fatalf("Failed to change modification time of %s: %v", inputFile, err)
+	}
+
+	if err := collectWithInput(t, inputFile, true, ""); err != nil {
+		t.Errorf("Expected the script of an inactive setting not to matter, got %v", err)
+	}
+	if fileExists(filepath.Join(repo, "PWNED")) {
+		t.Error("Expected the script not to run")
+	}
+}
+
+func TestScriptRunnerRefusesUntrustedScripts(t *testing.T) {
+	dir := t.TempDir()
+	item := ItemConfig{ShellScript: "touch ran; echo x"}
+	for name, runner := range map[string]scriptRunner{
+		"no trust store":    {dir: dir},
+		"unapproved script": {dir: dir, trusted: func(inputScript) bool { return false }},
+	} {
+		_, err := runner.run(context.Background(), "key", item, shellScriptSource, item.ShellScript)
+		if sourceErr := newSourceError("key", item, err); sourceErr.Code != "untrusted" {
+			t.Errorf("%s: expected the script to be refused, got %v", name, err)
+		}
+	}
+	if fileExists(filepath.Join(dir, "ran")) {
+		t.Error("Expected no script to run")
+	}
+}
+
+// fileExists reports whether path exists.
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
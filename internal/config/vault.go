@@ -0,0 +1,165 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// SourceVault is the scheme of values kept in a HashiCorp Vault KV version 2 secrets
+// engine: "vault:<path>#<field>", e.g. "vault:secret/data/myapp#dbPassword".  The path
+// is the API path of the secret, which has data/ after the mount.
+const SourceVault = "vault"
+
+// Environment variables the Vault provider reads, the same ones the vault CLI uses.
+// Without VAULT_TOKEN the token that `vault login` saved in ~/.vault-token is used.
+const (
+	vaultAddrEnvVar      = "VAULT_ADDR"
+	vaultTokenEnvVar     = "VAULT_TOKEN"
+	vaultNamespaceEnvVar = "VAULT_NAMESPACE"
+	vaultTokenFileName   = ".vault-token"
+	defaultVaultAddr     = "https://127.0.0.1:8200"
+)
+
+// httpProviderTimeout bounds each request a provider makes to a secret store.
+const httpProviderTimeout = 30 * time.Second
+
+// vaultProvider resolves values from Vault.  Each secret is read once per run, however
+// many settings take a field of it.
+type vaultProvider struct {
+	client  *http.Client
+	secrets map[string]map[string]interface{}
+}
+
+// newVaultProvider returns a Vault provider with an empty cache.
+func newVaultProvider() *vaultProvider {
+	return &vaultProvider{
+		client:  &http.Client{Timeout: httpProviderTimeout},
+		secrets: make(map[string]map[string]interface{}),
+	}
+}
+
+func (p *vaultProvider) Resolve(ctx context.Context, key string, item ItemConfig) (string, error) {
+	_, ref := parseSource(item.Source)
+	path, field, found := strings.Cut(ref, "#")
+	path = strings.Trim(path, "/")
+	if !found || path == "" || field == "" {
+		return "", &providerError{code: "invalid_source", message: fmt.Sprintf("expected 'vault:<path>#<field>', e.g. 'vault:secret/data/myapp#%s'", key)}
+	}
+
+	secret, err := p.readSecret(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	value, exists := secret[field]
+	if !exists {
+		return "", &providerError{code: "not_found", message: fmt.Sprintf("Vault secret '%s' has no field '%s'", path, field)}
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	// Numbers, booleans and objects are kept as the JSON Vault returned.
+	content, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+// readSecret returns the fields of the secret at path, reading it from Vault unless
+// it was read before in this run.
+func (p *vaultProvider) readSecret(ctx context.Context, path string) (map[string]interface{}, error) {
+	if secret, exists := p.secrets[path]; exists {
+		return secret, nil
+	}
+
+	addr := os.Getenv(vaultAddrEnvVar)
+	if addr == "" {
+		addr = defaultVaultAddr
+	}
+	token, err := vaultToken()
+	if err != nil {
+		return nil, err
+	}
+	url := strings.TrimRight(addr, "/") + "/v1/" + path
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s '%s': %v", vaultAddrEnvVar, addr, err)
+	}
+	request.Header.Set("X-Vault-Token", token)
+	if namespace := os.Getenv(vaultNamespaceEnvVar); namespace != "" {
+		request.Header.Set("X-Vault-Namespace", namespace)
+	}
+
+	response, err := p.client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach Vault at '%s': %v", addr, err)
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Vault secret '%s': %v", path, err)
+	}
+
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return nil, &providerError{code: "permission_denied", message: fmt.Sprintf("permission denied reading Vault secret '%s' at '%s'%s: check that %s or ~/%s holds a valid token whose policy allows reading it", path, addr, vaultErrors(body), vaultTokenEnvVar, vaultTokenFileName)}
+	case http.StatusNotFound:
+		return nil, &providerError{code: "not_found", message: fmt.Sprintf("Vault secret '%s' not found at '%s'%s: KV version 2 paths look like <mount>/data/<name>", path, addr, vaultErrors(body))}
+	default:
+		return nil, fmt.Errorf("failed to read Vault secret '%s' at '%s': %s%s", path, addr, response.Status, vaultErrors(body))
+	}
+
+	var secret struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &secret); err != nil {
+		return nil, fmt.Errorf("failed to parse Vault secret '%s': %v", path, err)
+	}
+	if secret.Data.Data == nil {
+		return nil, fmt.Errorf("Vault secret '%s' has no data: is it in a KV version 2 secrets engine, or was it deleted?", path)
+	}
+	p.secrets[path] = secret.Data.Data
+	return secret.Data.Data, nil
+}
+
+// vaultToken returns the token to authenticate to Vault with: VAULT_TOKEN, or else the
+// one `vault login` saved in ~/.vault-token.
+func vaultToken() (string, error) {
+	if token := os.Getenv(vaultTokenEnvVar); token != "" {
+		return token, nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(homeDir, vaultTokenFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("no Vault token: set %s or run vault login", vaultTokenEnvVar)
+	} else if err != nil {
+		return "", fmt.Errorf("failed to read Vault token: %v", err)
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+// vaultErrors returns the errors of a Vault error response, formatted to follow a
+// message, or "" if it has none.
+func vaultErrors(body []byte) string {
+	var response struct {
+		Errors []string `json:"errors"`
+	}
+	if json.Unmarshal(body, &response) != nil || len(response.Errors) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(response.Errors, "; ") + ")"
+}
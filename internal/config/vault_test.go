@@ -0,0 +1,91 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newVaultTestServer starts a stand-in for Vault holding the KV version 2 secret
+// secret/data/myapp, readable with the token "root".  requests counts the reads.
+func newVaultTestServer(t *testing.T, requests *int) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		switch {
+		case r.Header.Get("X-Vault-Token") != "root":
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors": ["permission denied"]}`))
+		case r.URL.Path == "/v1/secret/data/myapp":
+			w.Write([]byte(`{"data": {"data": {"dbPassword": "s3cret", "port": 5432}, "metadata": {"version": 1}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors": []}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv(vaultAddrEnvVar, server.URL)
+	t.Setenv(vaultTokenEnvVar, "root")
+}
+
+func TestVaultProvider(t *testing.T) {
+	requests := 0
+	newVaultTestServer(t, &requests)
+	configMap := map[string]ItemConfig{
+		"dbPassword": {Source: "vault:secret/data/myapp#dbPassword", Order: 1},
+		"dbPort":     {Source: "vault:secret/data/myapp#port", Order: 2},
+	}
+	if _, err := resolveValues(context.Background(), configMap, newProviders("repo-config.json", scriptRunner{}), scriptRunner{}); err != nil {
+		t.Fatalf("Failed to resolve sources: %v", err)
+	}
+	if configMap["dbPassword"].Default != "s3cret" || configMap["dbPort"].Default != "5432" {
+		t.Errorf("Unexpected values %q and %q", configMap["dbPassword"].Default, configMap["dbPort"].Default)
+	}
+	if requests != 1 {
+		t.Errorf("Expected the secret to be read once, got %d requests", requests)
+	}
+}
+
+func TestVaultProviderErrors(t *testing.T) {
+	requests := 0
+	newVaultTestServer(t, &requests)
+	for source, expected := range map[string][2]string{
+		"vault:secret/data/myapp#missing": {"has no field 'missing'", "not_found"},
+		"vault:secret/data/other#field":   {"'secret/data/other' not found", "not_found"},
+		"vault:secret/data/myapp":         {"expected 'vault:<path>#<field>'", "invalid_source"},
+	} {
+		_, err := resolveValues(context.Background(), map[string]ItemConfig{"key": {Source: source}}, newProviders("repo-config.json", scriptRunner{}), scriptRunner{})
+		var failed SourceErrors
+		if !errors.As(err, &failed) || !strings.Contains(err.Error(), expected[0]) || failed[0].Code != expected[1] {
+			t.Errorf("Expected a %s error containing %q for %s, got %v", expected[1], expected[0], source, err)
+		}
+	}
+
+	// A token from ~/.vault-token that Vault refuses.
+	home := t.TempDir()
+	setStoreEnv(t, home)
+	t.Setenv(vaultTokenEnvVar, "")
+	if err := os.WriteFile(filepath.Join(home, vaultTokenFileName), []byte("expired\n"), 0600); err != nil {
+		t.Fatalf("Failed to write token file: %v", err)
+	}
+	status := CollectConfigStatus(writeVaultInput(t), true)
+	if status.Status != StatusError || !strings.Contains(status.Message, "permission denied reading Vault secret 'secret/data/myapp'") || !strings.Contains(status.Message, "(permission denied)") {
+		t.Errorf("Expected a permission error, got %s", status)
+	}
+	if len(status.Errors) != 1 || status.Errors[0].Code != "permission_denied" {
+		t.Errorf("Expected a permission_denied error, got %v", status.Errors)
+	}
+}
+
+// writeVaultInput writes an input file whose only setting comes from Vault.
+func writeVaultInput(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"repo-config.yaml": "dbPassword:\n  source: vault:secret/data/myapp#dbPassword\n"})
+	return filepath.Join(dir, "repo-config.yaml")
+}
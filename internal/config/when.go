@@ -0,0 +1,168 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A when expression decides whether an item is active based on the values of other
+// items.  The grammar is deliberately small:
+//
+//	expr   := and ( "||" and )*
+//	and    := term ( "&&" term )*
+//	term   := key "==" value | key "!=" value | "!" key | key
+//
+// Values may be quoted with single or double quotes.  A bare key is true when the
+// referenced setting has a non-empty value.  For example:
+//
+//	"when": "storageBackend == cosmos && !useEmulator"
+
+// whenTerm is a single comparison in a when expression.
+type whenTerm struct {
+	key   string
+	op    string // "==", "!=", "set" or "unset"
+	value string
+}
+
+// whenExpr is a parsed when expression in disjunctive normal form.
+type whenExpr [][]whenTerm
+
+// parseWhen parses a when expression.
+func parseWhen(expression string) (whenExpr, error) {
+	var expr whenExpr
+	for _, orPart := range strings.Split(expression, "||") {
+		var terms []whenTerm
+		for _, andPart := range strings.Split(orPart, "&&") {
+			term, err := parseWhenTerm(strings.TrimSpace(andPart))
+			if err != nil {
+				return nil, fmt.Errorf("invalid when expression '%s': %v", expression, err)
+			}
+			terms = append(terms, term)
+		}
+		expr = append(expr, terms)
+	}
+	return expr, nil
+}
+
+// parseWhenTerm parses a single term of a when expression.
+func parseWhenTerm(text string) (whenTerm, error) {
+	if i, op := firstComparison(text); i >= 0 {
+		key := strings.TrimSpace(text[:i])
+		if !isWhenKey(key) {
+			return whenTerm{}, fmt.Errorf("'%s' is not a setting name", key)
+		}
+		value := strings.TrimSpace(text[i+len(op):])
+		if unquote(value) == value {
+			if j, _ := firstComparison(value); j >= 0 {
+				return whenTerm{}, fmt.Errorf("'%s' compares more than once: quote the value or join comparisons with && or ||", text)
+			}
+		}
+		return whenTerm{key: key, op: op, value: unquote(value)}, nil
+	}
+	op := "set"
+	if strings.HasPrefix(text, "!") {
+		op = "unset"
+		text = strings.TrimSpace(text[1:])
+	}
+	if !isWhenKey(text) {
+		return whenTerm{}, fmt.Errorf("'%s' is not a setting name", text)
+	}
+	return whenTerm{key: text, op: op}, nil
+}
+
+// firstComparison returns the position and operator of the first == or != in text,
+// or -1 if it has neither.
+func firstComparison(text string) (int, string) {
+	i, op := strings.Index(text, "=="), "=="
+	if j := strings.Index(text, "!="); j >= 0 && (i < 0 || j < i) {
+		i, op = j, "!="
+	}
+	return i, op
+}
+
+// isWhenKey reports whether text can be used as a setting name in a when expression.
+func isWhenKey(text string) bool {
+	return text != "" && !strings.ContainsAny(text, " \t\"'!=&|")
+}
+
+// unquote strips matching single or double quotes around value.
+func unquote(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// keys returns every setting name the expression refers to.
+func (expr whenExpr) keys() []string {
+	keys := []string{}
+	for _, terms := range expr {
+		for _, term := range terms {
+			keys = append(keys, term.key)
+		}
+	}
+	return keys
+}
+
+// eval evaluates the expression against the current values in configMap.
+func (expr whenExpr) eval(configMap map[string]ItemConfig) bool {
+	for _, terms := range expr {
+		matched := true
+		for _, term := range terms {
+			value := configMap[term.key].Default
+			switch term.op {
+			case "==":
+				matched = value == term.value
+			case "!=":
+				matched = value != term.value
+			case "set":
+				matched = value != ""
+			case "unset":
+				matched = value == ""
+			}
+			if !matched {
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// validateWhen checks that every when expression parses and only refers to settings
+// defined in configMap.
+func validateWhen(configMap map[string]ItemConfig) error {
+	for _, key := range orderedKeys(configMap) {
+		item := configMap[key]
+		if item.When == "" {
+			continue
+		}
+		expr, err := parseWhen(item.When)
+		if err != nil {
+			return fmt.Errorf("setting '%s': %v", key, err)
+		}
+		for _, ref := range expr.keys() {
+			if _, exists := configMap[ref]; !exists {
+				return fmt.Errorf("setting '%s': when expression refers to unknown setting '%s'", key, ref)
+			}
+		}
+	}
+	return nil
+}
+
+// isActive reports whether item is needed given the current values in configMap.
+// Items without a when expression are always active.  Expressions are validated when
+// the input file is loaded, so an expression that fails to parse here is treated as
+// active rather than silently hiding the setting.
+func isActive(configMap map[string]ItemConfig, item ItemConfig) bool {
+	if item.When == "" {
+		return true
+	}
+	expr, err := parseWhen(item.When)
+	if err != nil {
+		return true
+	}
+	return expr.eval(configMap)
+}
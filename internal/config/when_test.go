@@ -0,0 +1,125 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIsActive(t *testing.T) {
+	configMap := map[string]ItemConfig{
+		"storageBackend": {Default: "cosmos"},
+		"useEmulator":    {Default: ""},
+	}
+
+	tests := []struct {
+		when   string
+		active bool
+	}{
+		{"", true},
+		{"storageBackend == cosmos", true},
+		{"storageBackend == 'cosmos'", true},
+		{"storageBackend == \"blob\"", false},
+		{"storageBackend != blob", true},
+		{"useEmulator", false},
+		{"!useEmulator", true},
+		{"storageBackend == cosmos && useEmulator", false},
+		{"storageBackend == blob || !useEmulator", true},
+	}
+	for _, test := range tests {
+		if active := isActive(configMap, ItemConfig{When: test.when}); active != test.active {
+			t.Errorf("when '%s': expected %v, got %v", test.when, test.active, active)
+		}
+	}
+}
+
+func TestValidateWhen(t *testing.T) {
+	configMap := map[string]ItemConfig{
+		"storageBackend": {},
+		"cosmosKey":      {When: "storageBackend == cosmos"},
+	}
+	if err := validateWhen(configMap); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	configMap["cosmosKey"] = ItemConfig{When: "backend == cosmos"}
+	if err := validateWhen(configMap); err == nil || !strings.Contains(err.Error(), "unknown setting 'backend'") {
+		t.Errorf("Expected unknown setting error, got %v", err)
+	}
+
+	configMap["cosmosKey"] = ItemConfig{When: "storageBackend == cosmos &&"}
+	if err := validateWhen(configMap); err == nil {
+		t.Error("Expected error for invalid expression, got nil")
+	}
+
+	configMap["cosmosKey"] = ItemConfig{When: "storageBackend == cosmos == sql"}
+	if err := validateWhen(configMap); err == nil || !strings.Contains(err.Error(), "compares more than once") {
+		t.Errorf("Expected error for a chained comparison, got %v", err)
+	}
+
+	configMap["cosmosKey"] = ItemConfig{When: "storageBackend != 'a==b'"}
+	if err := validateWhen(configMap); err != nil {
+		t.Errorf("Expected a quoted value to be accepted, got %v", err)
+	}
+}
+
+func TestInactiveSettingsAreSkipped(t *testing.T) {
+	configMap := map[string]ItemConfig{
+		"storageBackend": {Default: "blob", TempEnvironmentVariableName: "STORAGE_BACKEND"},
+		"cosmosKey":      {When: "storageBackend == cosmos", TempEnvironmentVariableName: "COSMOS_KEY"},
+	}
+
+	if missing := checkForMissingValues(configMap); len(missing) != 0 {
+		t.Errorf("Expected no missing values, got %v", missing)
+	}
+	if compareConfigs(configMap, map[string]string{"storageBackend": "blob"}) {
+		t.Error("Expected a new inactive setting not to count as a change")
+	}
+
+	// Switching the backend makes the setting required.
+	item := configMap["storageBackend"]
+	item.Default = "cosmos"
+	configMap["storageBackend"] = item
+	if missing := checkForMissingValues(configMap); len(missing) != 1 || missing[0] != "cosmosKey" {
+		t.Errorf("Expected cosmosKey to be missing, got %v", missing)
+	}
+}
+
+func TestInactiveSettingsAreNotInEnvFile(t *testing.T) {
+	dir, err := os.MkdirTemp("", "config_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	originalHome := os.Getenv("HOME")
+	os.Setenv("HOME", dir)
+	defer os.Setenv("HOME", originalHome)
+
+	inputJSONFile := filepath.Join(dir, "when-config.json")
+	if err := os.WriteFile(inputJSONFile, []byte("{}"), 0644); err != nil {
+		t.Fatalf("Failed to write input JSON file: %v", err)
+	}
+
+	configMap := map[string]ItemConfig{
+		"storageBackend": {Default: "blob", TempEnvironmentVariableName: "STORAGE_BACKEND"},
+		"cosmosKey":      {Default: "old", When: "storageBackend == cosmos", TempEnvironmentVariableName: "COSMOS_KEY"},
+	}
+	saveTestValues(t, inputJSONFile, configMap)
+
+	_, envOutputFile, _ := GetOutputFilePaths(inputJSONFile)
+	envContent, err := os.ReadFile(envOutputFile)
+	if err != nil {
+		t.Fatalf("Failed to read .env output file: %v", err)
+	}
+	if strings.TrimSpace(string(envContent)) != "STORAGE_BACKEND=blob" {
+		t.Errorf("Expected only STORAGE_BACKEND in env file, got '%s'", envContent)
+	}
+
+	// The stored value is kept so switching back doesn't lose it.
+	values := readValues(t, inputJSONFile)
+	if values["cosmosKey"] != "old" {
+		t.Errorf("Expected cosmosKey to be kept in the values file, got %v", values)
+	}
+}
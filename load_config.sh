@@ -14,6 +14,11 @@ if [[ ! -f "repo-config" ]]; then
     go build -o repo-config
 fi
 
+# direnv loads the values through the .envrc at the root of the repo.  repo-config isn't on
+# the PATH here, so define use_repo_config for direnv with the one we just built.
+mkdir -p "${XDG_CONFIG_HOME:-$HOME/.config}/direnv/lib"
+./repo-config direnv > "${XDG_CONFIG_HOME:-$HOME/.config}/direnv/lib/repo-config.sh"
+
 output=$(./repo-config collect --json ./end-to-end-test/test-config.json --silent)
 result=$(echo "$output" | jq -r .status)
 
@@ -24,3 +29,8 @@ else
     echo -n  "config in "
     echo "$output" | jq -r .json_file
 fi
+
+# the .envrc is checked in; allow it so direnv loads it
+if [[ -f ".envrc" ]] && command -v direnv > /dev/null; then
+    direnv allow .
+fi